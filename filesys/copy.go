@@ -1,12 +1,94 @@
 package filesys
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 )
 
+var (
+	ErrSymlinkCycle = errors.New("filesys: symlink cycle")
+	ErrUnsupported  = errors.New("filesys: unsupported on this platform")
+)
+
+// SymlinkPolicy determines how Copy handles
+// symbolic links. See the available ones below.
+type SymlinkPolicy int
+
+const (
+	// SymlinkFollow copies the file or directory the link points
+	// to, as if it was in the place of the link. This is the default.
+	// Links pointing to one of their parent directories are detected,
+	// making Copy return ErrSymlinkCycle.
+	SymlinkFollow SymlinkPolicy = iota
+	// SymlinkCopy creates a new link with the same target.
+	// The target is kept as is, even if its relative.
+	SymlinkCopy
+	// SymlinkSkip ignores the links.
+	SymlinkSkip
+)
+
+// CopyOpt represents a configuration option
+// for Copy. See implementations below.
+type CopyOpt func(c *copier)
+
+// WithCopyMode preserves the permission bits of the copied
+// files and directories, including the setuid, setgid
+// and sticky ones. By default, directories are created
+// as 0775 and files as 0666, before the umask.
+func WithCopyMode() CopyOpt {
+	return func(c *copier) {
+		c.mode = true
+	}
+}
+
+// WithCopyTimes preserves the modification time of the
+// copied files and directories. Its also used as the
+// access time. Links times are not preserved.
+func WithCopyTimes() CopyOpt {
+	return func(c *copier) {
+		c.times = true
+	}
+}
+
+// WithCopyOwnership preserves the user and group owners of
+// the copied files, directories and links. It usually
+// requires privileges. Its only supported on unix systems,
+// so Copy returns ErrUnsupported elsewhere.
+func WithCopyOwnership() CopyOpt {
+	return func(c *copier) {
+		c.ownership = true
+	}
+}
+
+// WithCopyXattrs preserves the extended attributes of the
+// copied files and directories. Its only supported on Linux,
+// so Copy returns ErrUnsupported elsewhere.
+func WithCopyXattrs() CopyOpt {
+	return func(c *copier) {
+		c.xattrs = true
+	}
+}
+
+// WithCopySymlinks sets the SymlinkPolicy.
+// Defaults to SymlinkFollow.
+func WithCopySymlinks(p SymlinkPolicy) CopyOpt {
+	return func(c *copier) {
+		c.symlinks = p
+	}
+}
+
+type copier struct {
+	mode      bool
+	times     bool
+	ownership bool
+	xattrs    bool
+	symlinks  SymlinkPolicy
+}
+
 // Copy will copy directories and files recursively
 // from sourceRoot to destRoot.
 // It supports both, relative and absolute paths. It
@@ -15,6 +97,10 @@ import (
 // This function will abort the test if any operation fails.
 // In such case, it will not do any type of clean up operation.
 //
+// By default, only the contents are copied, and symbolic links
+// are followed. See the CopyOpt implementations for preserving
+// the file attributes and changing how links are handled.
+//
 // As an example, given the following filesystem tree:
 // /home/user/data/note1.txt
 // /home/user/data/note2.txt
@@ -27,7 +113,11 @@ import (
 // The copied data will be:
 // /home/user2/data/note1.txt
 // /home/user2/data/note2.txt
-func Copy(sourceRoot, destRoot string) error {
+func Copy(sourceRoot, destRoot string, opts ...CopyOpt) error {
+	c := &copier{}
+	for _, opt := range opts {
+		opt(c)
+	}
 
 	// Ensure we work with absolute paths from here.
 	sourceRoot, err := filepath.Abs(sourceRoot)
@@ -38,49 +128,123 @@ func Copy(sourceRoot, destRoot string) error {
 	if err != nil {
 		return err
 	}
+	return c.copy(sourceRoot, filepath.Join(destRoot, filepath.Base(sourceRoot)), nil)
+}
 
-	err = filepath.WalkDir(sourceRoot, func(path string, info fs.DirEntry, err error) error {
-		if err != nil {
+// copy copies the provided path, whatever its type. The ancestors
+// are the already visited parent directories, for detecting cycles.
+func (c *copier) copy(path, destPath string, ancestors []fs.FileInfo) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		switch c.symlinks {
+		case SymlinkSkip:
+			return nil
+		case SymlinkCopy:
+			return c.copySymlink(path, destPath, info)
+		}
+		if info, err = os.Stat(path); err != nil {
 			return err
 		}
-		destFirstElement := filepath.Base(sourceRoot)
-		var destPath string
-		if sourceRoot == path {
-			// The current walked path is the same as source root.
-			// So we just join with the root destination element.
-			destPath = filepath.Join(destRoot, destFirstElement)
-		} else {
-			rel, err := filepath.Rel(sourceRoot, path)
-			if err != nil {
-				return err
-			}
-			destPath = filepath.Join(destRoot, destFirstElement, rel)
+	}
+	if info.IsDir() {
+		return c.copyDir(path, destPath, info, ancestors)
+	}
+	return c.copyFile(path, destPath, info)
+}
+
+func (c *copier) copyDir(path, destPath string, info fs.FileInfo, ancestors []fs.FileInfo) error {
+	for _, a := range ancestors {
+		if os.SameFile(a, info) {
+			return fmt.Errorf("%w: %s", ErrSymlinkCycle, path)
 		}
+	}
+	// Contents need to be written before applying
+	// the mode, as it could be a read only one.
+	perm := fs.FileMode(0775)
+	if c.mode {
+		perm = 0700
+	}
+	if err := os.MkdirAll(destPath, perm); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	ancestors = append(ancestors, info)
+	for _, e := range entries {
+		if err := c.copy(filepath.Join(path, e.Name()), filepath.Join(destPath, e.Name()), ancestors); err != nil {
+			return err
+		}
+	}
+	return c.preserve(path, destPath, info)
+}
 
-		if info.IsDir() {
-			err = os.MkdirAll(destPath, 0775)
-			if err != nil {
-				return err
-			}
-			return nil
+func (c *copier) copyFile(path, destPath string, info fs.FileInfo) error {
+	fileFrom, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fileFrom.Close()
+	perm := fs.FileMode(0666)
+	if c.mode {
+		perm = 0600
+	}
+	fileTo, err := os.OpenFile(destPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer fileTo.Close()
+	if _, err := io.Copy(fileTo, fileFrom); err != nil {
+		return err
+	}
+	if err := fileTo.Close(); err != nil {
+		return err
+	}
+	return c.preserve(path, destPath, info)
+}
+
+func (c *copier) copySymlink(path, destPath string, info fs.FileInfo) error {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(target, destPath); err != nil {
+		return err
+	}
+	if c.ownership {
+		return lchown(destPath, info)
+	}
+	return nil
+}
+
+// preserve applies the configured attributes of the source
+// file or directory to the destination one. The ownership goes
+// first, as changing it could clear the setuid and setgid bits.
+func (c *copier) preserve(path, destPath string, info fs.FileInfo) error {
+	if c.ownership {
+		if err := lchown(destPath, info); err != nil {
+			return err
 		}
-		fileFrom, err := os.Open(path)
-		if err != nil {
+	}
+	if c.xattrs {
+		if err := copyXattrs(path, destPath); err != nil {
 			return err
 		}
-		defer fileFrom.Close()
-		fileTo, err := os.Create(destPath)
-		if err != nil {
+	}
+	if c.mode {
+		mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if err := os.Chmod(destPath, mode); err != nil {
 			return err
 		}
-		defer fileTo.Close()
-		if _, err := io.Copy(fileTo, fileFrom); err != nil {
+	}
+	if c.times {
+		if err := os.Chtimes(destPath, info.ModTime(), info.ModTime()); err != nil {
 			return err
 		}
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 	return nil
 }
@@ -0,0 +1,52 @@
+package filesys_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/filesys"
+)
+
+func TestCopyPreservesXattrs(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "note.txt")
+	require.NoError(t, os.WriteFile(src, []byte("note"), 0644))
+	if err := syscall.Setxattr(src, "user.kit", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+
+	tmp := t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp, filesys.WithCopyXattrs()))
+
+	value := make([]byte, 16)
+	n, err := syscall.Getxattr(filepath.Join(tmp, "note.txt"), "user.kit", value)
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(value[:n]))
+}
+
+func TestCopyPreservesOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+	src := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.Mkdir(src, 0775))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "note.txt"), []byte("note"), 0644))
+	require.NoError(t, os.Symlink("note.txt", filepath.Join(src, "link.txt")))
+	for _, name := range []string{"", "note.txt", "link.txt"} {
+		require.NoError(t, os.Lchown(filepath.Join(src, name), 1234, 4321))
+	}
+
+	tmp := t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp, filesys.WithCopyOwnership(), filesys.WithCopySymlinks(filesys.SymlinkCopy)))
+
+	for _, name := range []string{"", "note.txt", "link.txt"} {
+		info, err := os.Lstat(filepath.Join(tmp, "data", name))
+		require.NoError(t, err)
+		st := info.Sys().(*syscall.Stat_t)
+		assert.Equal(t, uint32(1234), st.Uid, name)
+		assert.Equal(t, uint32(4321), st.Gid, name)
+	}
+}
@@ -1,11 +1,15 @@
 package filesys_test
 
 import (
+	"errors"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.eloylp.dev/kit/filesys"
 )
 
@@ -30,6 +34,108 @@ func TestCopySingleFile(t *testing.T) {
 	assert.Equal(t, "note1.txt", RelPath(t, tmp, filePaths[0]))
 }
 
+func TestCopyPreservesMode(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.Mkdir(src, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "run.sh"), []byte("echo"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "secret"), []byte("s"), 0400))
+	require.NoError(t, os.Chmod(src, 0550))
+	defer os.Chmod(src, 0750) //nolint:errcheck
+
+	tmp := t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp, filesys.WithCopyMode()))
+	defer os.Chmod(filepath.Join(tmp, "data"), 0750) //nolint:errcheck
+
+	assertMode(t, filepath.Join(tmp, "data"), fs.ModeDir|0550)
+	assertMode(t, filepath.Join(tmp, "data", "run.sh"), 0755)
+	assertMode(t, filepath.Join(tmp, "data", "secret"), 0400)
+}
+
+func TestCopyPreservesTimes(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.Mkdir(src, 0775))
+	file := filepath.Join(src, "note.txt")
+	require.NoError(t, os.WriteFile(file, []byte("note"), 0644))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(file, mtime, mtime))
+	require.NoError(t, os.Chtimes(src, mtime, mtime))
+
+	tmp := t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp, filesys.WithCopyTimes()))
+
+	for _, path := range []string{"data", "data/note.txt"} {
+		info, err := os.Stat(filepath.Join(tmp, path))
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(info.ModTime()), path)
+	}
+}
+
+func TestCopySymlinks(t *testing.T) {
+	src := symlinksTree(t)
+
+	tmp := t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp, filesys.WithCopySymlinks(filesys.SymlinkCopy)))
+	target, err := os.Readlink(filepath.Join(tmp, "data", "link.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "note.txt", target)
+	target, err = os.Readlink(filepath.Join(tmp, "data", "linkdir"))
+	require.NoError(t, err)
+	assert.Equal(t, "sub", target)
+
+	tmp = t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp, filesys.WithCopySymlinks(filesys.SymlinkSkip)))
+	assert.Equal(t, []string{"data", "data/note.txt", "data/sub", "data/sub/note.txt"}, relPaths(t, tmp))
+
+	tmp = t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp))
+	assert.Equal(t, []string{
+		"data", "data/link.txt", "data/linkdir", "data/linkdir/note.txt",
+		"data/note.txt", "data/sub", "data/sub/note.txt",
+	}, relPaths(t, tmp))
+	data, err := os.ReadFile(filepath.Join(tmp, "data", "link.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "note", string(data))
+	info, err := os.Lstat(filepath.Join(tmp, "data", "linkdir"))
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestCopySymlinkCycle(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0775))
+	require.NoError(t, os.Symlink("..", filepath.Join(src, "sub", "loop")))
+
+	err := filesys.Copy(src, t.TempDir())
+	assert.True(t, errors.Is(err, filesys.ErrSymlinkCycle), err)
+
+	tmp := t.TempDir()
+	require.NoError(t, filesys.Copy(src, tmp, filesys.WithCopySymlinks(filesys.SymlinkCopy)))
+}
+
+func symlinksTree(t *testing.T) string {
+	src := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0775))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "note.txt"), []byte("note"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "note.txt"), []byte("sub note"), 0644))
+	require.NoError(t, os.Symlink("note.txt", filepath.Join(src, "link.txt")))
+	require.NoError(t, os.Symlink("sub", filepath.Join(src, "linkdir")))
+	return src
+}
+
+func assertMode(t *testing.T, path string, want fs.FileMode) {
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, info.Mode(), path)
+}
+
+func relPaths(t *testing.T, root string) []string {
+	var paths []string
+	for _, path := range FilesInDest(t, root) {
+		paths = append(paths, filepath.ToSlash(RelPath(t, root, path)))
+	}
+	return paths
+}
+
 func FilesInDest(t *testing.T, root string) (entries []string) {
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if path == "." {
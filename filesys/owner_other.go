@@ -0,0 +1,11 @@
+//go:build !unix
+
+package filesys
+
+import (
+	"io/fs"
+)
+
+func lchown(path string, info fs.FileInfo) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package filesys
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// lchown sets the owners of the provided file info to the
+// path, without following it in case its a symbolic link.
+func lchown(path string, info fs.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ErrUnsupported
+	}
+	return os.Lchown(path, int(st.Uid), int(st.Gid))
+}
@@ -0,0 +1,52 @@
+package filesys
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// copyXattrs copies all the extended attributes from path
+// to destPath. Sources in filesystems without extended
+// attributes support are considered to have none.
+func copyXattrs(path, destPath string) error {
+	names, err := xattr(func(dest []byte) (int, error) {
+		return syscall.Listxattr(path, dest)
+	})
+	if err == syscall.ENOTSUP {
+		return nil
+	}
+	if err != nil {
+		return &os.PathError{Op: "listxattr", Path: path, Err: err}
+	}
+	for _, name := range strings.Split(string(names), "\x00") {
+		if name == "" {
+			continue
+		}
+		value, err := xattr(func(dest []byte) (int, error) {
+			return syscall.Getxattr(path, name, dest)
+		})
+		if err != nil {
+			return &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+		if err := syscall.Setxattr(destPath, name, value, 0); err != nil {
+			return &os.PathError{Op: "setxattr", Path: destPath, Err: err}
+		}
+	}
+	return nil
+}
+
+// xattr calls fn first for knowing the size of
+// the data, and then for actually reading it.
+func xattr(fn func(dest []byte) (int, error)) ([]byte, error) {
+	size, err := fn(nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	data := make([]byte, size)
+	size, err = fn(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
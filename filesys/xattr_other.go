@@ -0,0 +1,7 @@
+//go:build !linux
+
+package filesys
+
+func copyXattrs(path, destPath string) error {
+	return ErrUnsupported
+}
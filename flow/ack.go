@@ -0,0 +1,345 @@
+package flow
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.eloylp.dev/kit/moment"
+)
+
+const defaultAckVisibilityTimeout = 30 * time.Second
+
+// Delivery represents an element delivered to an AckSubscription.
+// It must be acknowledged with Ack once processed. If not, it will
+// be redelivered.
+type Delivery[T any] struct {
+	Slot[T]
+	// Attempt is the number of times the element
+	// was delivered, including this one.
+	Attempt int
+
+	id  uint64
+	sub *AckSubscription[T]
+}
+
+// Ack confirms the element was processed, so it will not be
+// redelivered. It returns ErrDeliveryNotFound if the delivery
+// was already acknowledged or it was redelivered in the meantime,
+// due to the visibility timeout.
+func (d *Delivery[T]) Ack() error {
+	return d.sub.settle(d, false)
+}
+
+// Nack rejects the element, so it will be redelivered after
+// the configured delay. See WithAckNackDelay. It follows
+// the same rules as Ack regarding ErrDeliveryNotFound.
+func (d *Delivery[T]) Nack() error {
+	return d.sub.settle(d, true)
+}
+
+// inFlight represents an element that was delivered, but not
+// acknowledged yet. Once readyAt is reached, its redelivered.
+type inFlight[T any] struct {
+	slot     *Slot[T]
+	attempts int
+	readyAt  time.Time
+}
+
+// AckSubscription is a Subscription that provides at-least-once
+// delivery semantics. Consumed elements stay in flight until they
+// are acknowledged. Unacknowledged ones are redelivered once
+// the visibility timeout is exceeded, or after the configured
+// delay when they are explicitly rejected with Nack. Redelivered
+// elements have preference over the new ones.
+//
+// Optionally, elements exceeding a max number of attempts are
+// published to a dead-letter Fanout. See AckOpt implementations.
+//
+// Once the Fanout is closed, elements in flight keep being redelivered
+// until they are acknowledged. Drain does not wait for them, as they
+// are no longer queued, so consumers should keep consuming until
+// io.EOF for not losing any.
+//
+// Its IMPORTANT to call Cancel once the subscription is not needed
+// anymore. Elements in flight at that moment, and the remaining
+// ones delivered afterwards, are discarded.
+type AckSubscription[T any] struct {
+	sub         *Subscription[T]
+	now         moment.NowFunc
+	clock       moment.Clock
+	visibility  time.Duration
+	nackDelay   time.Duration
+	maxAttempts int
+	deadLetter  *Fanout[T]
+	subOpts     []SubscriberOpt[T]
+	inFlight    map[uint64]*inFlight[T]
+	nextID      uint64
+	l           sync.Mutex
+	// exhausted is set once the underlying channel
+	// is closed and all its elements consumed.
+	exhausted atomic.Bool
+	cancelled atomic.Bool
+}
+
+// AckOpt represents a configuration option for
+// an AckSubscription. See implementations below.
+type AckOpt[T any] func(s *AckSubscription[T])
+
+// WithAckVisibilityTimeout sets for how long a delivered element
+// is waiting for its acknowledgement, before being redelivered.
+// Defaults to 30 seconds.
+func WithAckVisibilityTimeout[T any](d time.Duration) AckOpt[T] {
+	return func(s *AckSubscription[T]) {
+		s.visibility = d
+	}
+}
+
+// WithAckNackDelay sets the delay before redelivering
+// elements rejected with Nack. Defaults to zero.
+func WithAckNackDelay[T any](d time.Duration) AckOpt[T] {
+	return func(s *AckSubscription[T]) {
+		s.nackDelay = d
+	}
+}
+
+// WithAckMaxAttempts sets the max number of deliveries of an
+// element. Once exceeded, the element is published in the
+// dead-letter Fanout, if any, or discarded. Zero, the default,
+// means unlimited attempts.
+func WithAckMaxAttempts[T any](n int) AckOpt[T] {
+	return func(s *AckSubscription[T]) {
+		s.maxAttempts = n
+	}
+}
+
+// WithAckDeadLetter sets the Fanout where the elements exceeding
+// the max attempts will be published. See WithAckMaxAttempts.
+func WithAckDeadLetter[T any](fo *Fanout[T]) AckOpt[T] {
+	return func(s *AckSubscription[T]) {
+		s.deadLetter = fo
+	}
+}
+
+// WithAckClock sets the clock used for the visibility timeouts
+// and the nack delays. By default, the system one is used, with
+// the current time provided by the Fanout. Useful for testing.
+// See moment.FakeClock.
+func WithAckClock[T any](clock moment.Clock) AckOpt[T] {
+	return func(s *AckSubscription[T]) {
+		s.clock = clock
+		s.now = clock.Now
+	}
+}
+
+// WithAckSubscriberOpts sets the options for the
+// underlying subscriber. See NewSubscription.
+func WithAckSubscriberOpts[T any](opts ...SubscriberOpt[T]) AckOpt[T] {
+	return func(s *AckSubscription[T]) {
+		s.subOpts = append(s.subOpts, opts...)
+	}
+}
+
+// NewAckSubscription is same as NewSubscription, but it returns
+// an *AckSubscription, which provides at-least-once delivery.
+func (fo *Fanout[T]) NewAckSubscription(uuid string, opts ...AckOpt[T]) *AckSubscription[T] {
+	s := &AckSubscription[T]{
+		now:        fo.now,
+		clock:      moment.NewClock(),
+		visibility: defaultAckVisibilityTimeout,
+		inFlight:   make(map[uint64]*inFlight[T]),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.sub = fo.NewSubscription(uuid, s.subOpts...)
+	return s
+}
+
+// Consume will block until an element is ready to be delivered,
+// either a new one or a redelivery. See ConsumeContext.
+func (s *AckSubscription[T]) Consume() (*Delivery[T], error) {
+	return s.ConsumeContext(context.Background())
+}
+
+// ConsumeContext will block until an element is ready to be
+// delivered, or until the provided context ends. In such case,
+// ctx.Err() will be returned.
+//
+// In case the Fanout is closed, an io.EOF error will be returned,
+// once all the remaining new elements are consumed and the ones in
+// flight acknowledged. Until then, the latter keep being redelivered.
+// In case the subscription is cancelled, io.EOF is returned once
+// the remaining new elements are consumed, discarding the ones
+// in flight.
+func (s *AckSubscription[T]) ConsumeContext(ctx context.Context) (*Delivery[T], error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		d, wait := s.redelivery()
+		if d != nil {
+			return d, nil
+		}
+		slot, err := s.receive(ctx, wait)
+		if err == errExpired || (slot == nil && err == nil) {
+			continue
+		}
+		if err == io.EOF && wait > 0 {
+			if !s.cancelled.Load() {
+				// Elements in flight still need to be redelivered.
+				s.exhausted.Store(true)
+				continue
+			}
+			s.discard()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.deliver(slot), nil
+	}
+}
+
+// receive waits for a new element from the underlying subscription.
+// If wait is greater than zero, it gives up after such time, returning
+// a nil slot, so the caller can check again the redeliveries.
+// Once the underlying channel is exhausted, it only waits for them.
+func (s *AckSubscription[T]) receive(ctx context.Context, wait time.Duration) (*Slot[T], error) {
+	ch := s.sub.sub.ch
+	if s.exhausted.Load() {
+		if wait == 0 {
+			return nil, io.EOF
+		}
+		ch = nil
+	}
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := s.clock.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, nil
+	case slot, ok := <-ch:
+		return s.sub.received(slot, ok)
+	}
+}
+
+// deliver registers a new element as in flight.
+func (s *AckSubscription[T]) deliver(slot *Slot[T]) *Delivery[T] {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.nextID++
+	e := &inFlight[T]{
+		slot:     slot,
+		attempts: 1,
+		readyAt:  s.now().Add(s.visibility),
+	}
+	s.inFlight[s.nextID] = e
+	return s.delivery(s.nextID, e)
+}
+
+// redelivery returns the in flight element that should be redelivered,
+// if any. Elements exceeding the max attempts are sent to the dead-letter
+// Fanout. If there is no element ready yet, it returns how much time to
+// wait for the next one. Zero if there are no elements in flight.
+func (s *AckSubscription[T]) redelivery() (d *Delivery[T], wait time.Duration) {
+	var deadLetters []*Slot[T]
+	defer func() {
+		// Outside the lock, as publishing could block.
+		for _, sl := range deadLetters {
+			if s.deadLetter != nil {
+				_, _ = s.deadLetter.Publish(sl.Elem)
+			}
+		}
+	}()
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	now := s.now()
+	var readyID uint64
+	var ready *inFlight[T]
+	for id, e := range s.inFlight {
+		if e.readyAt.After(now) {
+			if w := e.readyAt.Sub(now); wait == 0 || w < wait {
+				wait = w
+			}
+			continue
+		}
+		if s.maxAttempts > 0 && e.attempts >= s.maxAttempts {
+			delete(s.inFlight, id)
+			deadLetters = append(deadLetters, e.slot)
+			continue
+		}
+		if ready == nil || e.readyAt.Before(ready.readyAt) || (e.readyAt.Equal(ready.readyAt) && id < readyID) {
+			readyID, ready = id, e
+		}
+	}
+	if ready == nil {
+		return nil, wait
+	}
+	ready.attempts++
+	ready.readyAt = now.Add(s.visibility)
+	return s.delivery(readyID, ready), 0
+}
+
+func (s *AckSubscription[T]) delivery(id uint64, e *inFlight[T]) *Delivery[T] {
+	return &Delivery[T]{
+		Slot:    *e.slot,
+		Attempt: e.attempts,
+		id:      id,
+		sub:     s,
+	}
+}
+
+// settle acknowledges or rejects the delivery.
+func (s *AckSubscription[T]) settle(d *Delivery[T], nack bool) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	e, ok := s.inFlight[d.id]
+	if !ok || e.attempts != d.Attempt {
+		return ErrDeliveryNotFound
+	}
+	if nack {
+		e.readyAt = s.now().Add(s.nackDelay)
+		return nil
+	}
+	delete(s.inFlight, d.id)
+	return nil
+}
+
+// InFlight returns the number of delivered elements
+// pending of acknowledgement.
+func (s *AckSubscription[T]) InFlight() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return len(s.inFlight)
+}
+
+// Handle returns the handle of the underlying
+// subscriber. See Subscription.Handle.
+func (s *AckSubscription[T]) Handle() uint64 {
+	return s.sub.Handle()
+}
+
+// Cancel terminates the subscription. It follows the same
+// semantics as CancelFunc. Elements in flight are discarded.
+func (s *AckSubscription[T]) Cancel() error {
+	s.cancelled.Store(true)
+	s.discard()
+	return s.sub.Cancel()
+}
+
+// discard forgets all the elements in flight.
+func (s *AckSubscription[T]) discard() {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.inFlight = make(map[uint64]*inFlight[T])
+}
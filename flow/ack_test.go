@@ -0,0 +1,216 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+	"go.eloylp.dev/kit/moment"
+)
+
+func TestAckSubscription_Ack(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewAckSubscription("", flow.WithAckClock[int](clock))
+	defer sub.Cancel()
+
+	mustPublish(fo, 1)
+
+	d, err := sub.Consume()
+	require.NoError(t, err)
+	assert.Equal(t, 1, d.Elem)
+	assert.Equal(t, 1, d.Attempt)
+	assert.Equal(t, 1, sub.InFlight())
+	assert.NoError(t, d.Ack())
+	assert.Equal(t, flow.ErrDeliveryNotFound, d.Ack())
+	assert.Equal(t, 0, sub.InFlight())
+
+	clock.Advance(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = sub.ConsumeContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err, "acknowledged elements should not be redelivered")
+}
+
+func TestAckSubscription_VisibilityTimeout(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewAckSubscription("",
+		flow.WithAckClock[int](clock),
+		flow.WithAckVisibilityTimeout[int](time.Second),
+	)
+	defer sub.Cancel()
+
+	mustPublish(fo, 1)
+
+	first, err := sub.Consume()
+	require.NoError(t, err)
+
+	redelivered := consumeAsync(sub)
+	clock.BlockUntilCreated(1)
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-redelivered:
+		t.Fatal("redelivered before the visibility timeout")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+
+	second := <-redelivered
+	require.NotNil(t, second)
+	assert.Equal(t, 1, second.Elem)
+	assert.Equal(t, 2, second.Attempt)
+	assert.Equal(t, first.ID, second.ID)
+
+	assert.Equal(t, flow.ErrDeliveryNotFound, first.Ack(), "stale deliveries cannot be acknowledged")
+	assert.NoError(t, second.Ack())
+}
+
+func TestAckSubscription_NackDelay(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewAckSubscription("",
+		flow.WithAckClock[int](clock),
+		flow.WithAckNackDelay[int](time.Second),
+	)
+	defer sub.Cancel()
+
+	mustPublish(fo, 1)
+
+	d, err := sub.Consume()
+	require.NoError(t, err)
+	require.NoError(t, d.Nack())
+
+	redelivered := consumeAsync(sub)
+	clock.BlockUntilCreated(1)
+	clock.Advance(time.Second)
+
+	d = <-redelivered
+	require.NotNil(t, d)
+	assert.Equal(t, 1, d.Elem)
+	assert.Equal(t, 2, d.Attempt)
+}
+
+func TestAckSubscription_RedeliveriesFirst(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewAckSubscription("")
+	defer sub.Cancel()
+
+	mustPublish(fo, 1)
+	mustPublish(fo, 2)
+
+	d, err := sub.Consume()
+	require.NoError(t, err)
+	require.NoError(t, d.Nack())
+
+	d, err = sub.Consume()
+	require.NoError(t, err)
+	assert.Equal(t, 1, d.Elem)
+	require.NoError(t, d.Ack())
+
+	d, err = sub.Consume()
+	require.NoError(t, err)
+	assert.Equal(t, 2, d.Elem)
+}
+
+func TestAckSubscription_MaxAttemptsDeadLetter(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	dlq := flow.NewFanout[int](10)
+	consumeDead, cancelDead := dlq.Subscribe()
+	defer cancelDead()
+
+	sub := fo.NewAckSubscription("",
+		flow.WithAckMaxAttempts[int](2),
+		flow.WithAckDeadLetter(dlq),
+	)
+	defer sub.Cancel()
+
+	mustPublish(fo, 1)
+	for attempt := 1; attempt <= 2; attempt++ {
+		d, err := sub.Consume()
+		require.NoError(t, err)
+		assert.Equal(t, attempt, d.Attempt)
+		require.NoError(t, d.Nack())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := sub.ConsumeContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, sub.InFlight())
+	assertConsumed(t, consumeDead, 1)
+}
+
+func TestAckSubscription_Cancel(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewAckSubscription("", flow.WithAckSubscriberOpts(flow.WithSubscriberBuffLen[int](5)))
+
+	mustPublish(fo, 1)
+	mustPublish(fo, 2)
+
+	d, err := sub.Consume()
+	require.NoError(t, err)
+	require.NoError(t, sub.Cancel())
+	assert.Equal(t, flow.ErrDeliveryNotFound, d.Ack(), "in flight elements are discarded")
+
+	d, err = sub.Consume()
+	require.NoError(t, err)
+	assert.Equal(t, 2, d.Elem)
+	_, err = sub.Consume()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestAckSubscription_Close(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewAckSubscription("",
+		flow.WithAckClock[int](clock),
+		flow.WithAckVisibilityTimeout[int](time.Second),
+	)
+	defer sub.Cancel()
+
+	mustPublish(fo, 1)
+	mustPublish(fo, 2)
+
+	unacked, err := sub.Consume()
+	require.NoError(t, err)
+	require.NoError(t, fo.Close())
+
+	d, err := sub.Consume()
+	require.NoError(t, err)
+	assert.Equal(t, 2, d.Elem)
+	require.NoError(t, d.Ack())
+
+	redelivered := consumeAsync(sub)
+	// One timer per each wait for the unacked element: while
+	// consuming 2, while finding the closed channel and after it.
+	clock.BlockUntilCreated(3)
+	clock.Advance(time.Second)
+
+	d = <-redelivered
+	require.NotNil(t, d, "in flight elements are redelivered after close")
+	assert.Equal(t, unacked.Elem, d.Elem)
+	assert.Equal(t, 2, d.Attempt)
+	require.NoError(t, d.Ack())
+
+	_, err = sub.Consume()
+	assert.Equal(t, io.EOF, err)
+}
+
+// consumeAsync consumes from the subscription in the background,
+// sending the delivery to the returned channel, or nil on error.
+func consumeAsync[T any](sub *flow.AckSubscription[T]) <-chan *flow.Delivery[T] {
+	ch := make(chan *flow.Delivery[T], 1)
+	go func() {
+		d, _ := sub.Consume()
+		ch <- d
+	}()
+	return ch
+}
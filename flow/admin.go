@@ -0,0 +1,163 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SubscriberInfo describes a single live subscriber of a
+// Fanout. Unlike Status, subscribers sharing the same UUID
+// are not aggregated. See Fanout.Subscribers.
+type SubscriberInfo struct {
+	// Handle uniquely identifies the subscriber among the
+	// ones of the same Fanout. See Subscription.Handle.
+	Handle uint64 `json:"handle"`
+	// UUID is the user provided UUID of the subscriber.
+	UUID string `json:"uuid"`
+	// Group tells whether the subscriber belongs to a
+	// consumer group. See WithSubscriberGroup.
+	Group bool `json:"group"`
+	// SubscribedAt is the moment of the subscription.
+	SubscribedAt time.Time `json:"subscribed_at"`
+	// Capacity is the buffer length of the subscriber.
+	Capacity int `json:"capacity"`
+	// Pending is the number of queued elements.
+	Pending int `json:"pending"`
+	// Dropped is the number of discarded elements
+	// due to the full buffer.
+	Dropped uint64 `json:"dropped"`
+	// Expired is the number of skipped elements
+	// due to their age. See WithFanoutMaxAge.
+	Expired uint64 `json:"expired"`
+	// LastConsume is the moment of the last consume
+	// operation. Zero if there was no consumption.
+	LastConsume time.Time `json:"last_consume"`
+}
+
+// Subscribers returns the information of each one of the
+// live subscribers, ordered by their subscription.
+func (fo *Fanout[T]) Subscribers() []SubscriberInfo {
+	var infos []SubscriberInfo
+	fo.subscribers.each(func(s *subscriber[T]) {
+		infos = append(infos, SubscriberInfo{
+			Handle:       s.handle,
+			UUID:         s.uuid,
+			Group:        s.grouped,
+			SubscribedAt: s.createdAt,
+			Capacity:     cap(s.ch),
+			Pending:      len(s.ch),
+			Dropped:      s.dropped.Load(),
+			Expired:      s.expired.Load(),
+			LastConsume:  s.lastConsumeTime(),
+		})
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Handle < infos[j].Handle
+	})
+	return infos
+}
+
+// Unsubscribe forcibly unsubscribes the subscriber with the
+// provided handle. Its consumers will get io.EOF once they consume
+// the remaining elements, same as if it was cancelled by its owner,
+// whose cancel function will return ErrSubscriberNotFound afterwards.
+//
+// It returns ErrSubscriberNotFound if there is no such subscriber.
+func (fo *Fanout[T]) Unsubscribe(handle uint64) error {
+	var found *subscriber[T]
+	fo.subscribers.each(func(s *subscriber[T]) {
+		if s.handle == handle {
+			found = s
+		}
+	})
+	if found == nil {
+		return ErrSubscriberNotFound
+	}
+	return fo.unsubscribe(found)
+}
+
+// UnsubscribeUUID is same as Unsubscribe, but for all the
+// subscribers with the provided UUID. It returns how many
+// of them were unsubscribed.
+func (fo *Fanout[T]) UnsubscribeUUID(uuid string) (int, error) {
+	var found []*subscriber[T]
+	fo.subscribers.each(func(s *subscriber[T]) {
+		if s.uuid == uuid {
+			found = append(found, s)
+		}
+	})
+	var n int
+	for _, s := range found {
+		if fo.unsubscribe(s) == nil {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, ErrSubscriberNotFound
+	}
+	return n, nil
+}
+
+type adminHandler[T any] struct {
+	fo *Fanout[T]
+}
+
+// NewAdminHandler returns an http.Handler for inspecting
+// and managing the subscribers of the Fanout:
+//
+//   - GET lists the live subscribers as a JSON array.
+//     See SubscriberInfo.
+//   - DELETE with a "handle" or "uuid" query parameter forcibly
+//     unsubscribes the matching subscribers. See Unsubscribe.
+//
+// It should be protected, as any other admin endpoint.
+// See the auth middleware in the http/middleware package.
+func NewAdminHandler[T any](fo *Fanout[T]) http.Handler {
+	return &adminHandler[T]{fo: fo}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *adminHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		infos := h.fo.Subscribers()
+		if infos == nil {
+			infos = []SubscriberInfo{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(infos)
+	case http.MethodDelete:
+		h.unsubscribe(w, r)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *adminHandler[T]) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var err error
+	switch {
+	case query.Has("handle"):
+		var handle uint64
+		handle, err = strconv.ParseUint(query.Get("handle"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid handle", http.StatusBadRequest)
+			return
+		}
+		err = h.fo.Unsubscribe(handle)
+	case query.Has("uuid"):
+		_, err = h.fo.UnsubscribeUUID(query.Get("uuid"))
+	default:
+		http.Error(w, "handle or uuid query parameter expected", http.StatusBadRequest)
+		return
+	}
+	if err == ErrSubscriberNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,129 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+	"go.eloylp.dev/kit/moment"
+)
+
+func TestFanout_Subscribers(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](2, flow.WithFanoutNowFunc[int](clock.Now))
+	a := fo.NewSubscription("a")
+	defer a.Cancel()
+	b := fo.NewSubscription("", flow.WithSubscriberBuffLen[int](5))
+	defer b.Cancel()
+	c := fo.NewSubscription("", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	defer c.Cancel()
+
+	mustPublish(fo, 1)
+	mustPublish(fo, 2)
+	mustPublish(fo, 3)
+	_, err := b.Consume()
+	require.NoError(t, err)
+
+	infos := fo.Subscribers()
+	require.Len(t, infos, 3)
+	assert.Equal(t, flow.SubscriberInfo{
+		Handle:       a.Handle(),
+		UUID:         "a",
+		SubscribedAt: clock.Now(),
+		Capacity:     2,
+		Pending:      2,
+		Dropped:      1,
+	}, infos[0])
+
+	assert.Equal(t, b.Handle(), infos[1].Handle)
+	assert.Equal(t, "", infos[1].UUID)
+	assert.Equal(t, 5, infos[1].Capacity)
+	assert.Equal(t, 2, infos[1].Pending)
+	assert.False(t, infos[1].LastConsume.IsZero())
+
+	assert.Equal(t, c.Handle(), infos[2].Handle)
+	assert.True(t, infos[2].Group)
+	assert.Equal(t, 2, infos[2].Pending)
+}
+
+func TestFanout_UnsubscribeHandle(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("a")
+	other := fo.NewSubscription("a")
+	defer other.Cancel()
+
+	mustPublish(fo, 1)
+	require.NoError(t, fo.Unsubscribe(sub.Handle()))
+	assert.Equal(t, flow.ErrSubscriberNotFound, fo.Unsubscribe(sub.Handle()))
+	assert.Equal(t, 1, fo.ActiveSubscribers())
+
+	slot, err := sub.Consume()
+	require.NoError(t, err, "remaining elements can be still consumed")
+	assert.Equal(t, 1, slot.Elem)
+	_, err = sub.Consume()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, flow.ErrSubscriberNotFound, sub.Cancel())
+}
+
+func TestFanout_UnsubscribeUUID(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	fo.NewSubscription("a")
+	fo.NewSubscription("a", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	b := fo.NewSubscription("b")
+	defer b.Cancel()
+
+	n, err := fo.UnsubscribeUUID("a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1, fo.ActiveSubscribers())
+
+	_, err = fo.UnsubscribeUUID("a")
+	assert.Equal(t, flow.ErrSubscriberNotFound, err)
+}
+
+func TestAdminHandler(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10, flow.WithFanoutNowFunc[int](clock.Now))
+	a := fo.NewSubscription("a")
+	fo.NewSubscription("b")
+	fo.NewSubscription("b")
+	h := flow.NewAdminHandler(fo)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var infos []flow.SubscriberInfo
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&infos))
+	assert.Equal(t, fo.Subscribers(), infos)
+
+	handle := strconv.FormatUint(a.Handle(), 10)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/?handle="+handle).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/?handle="+handle).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, "/?handle=abc").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/?uuid=b").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/?uuid=b").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, "/").Code)
+	assert.Equal(t, 0, fo.ActiveSubscribers())
+
+	rec = serve(http.MethodGet, "/")
+	assert.Equal(t, "[]\n", rec.Body.String())
+
+	rec = serve(http.MethodPost, "/")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, DELETE", rec.Header().Get("Allow"))
+}
@@ -0,0 +1,137 @@
+package flow
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultBridgeHeartbeat = 15 * time.Second
+
+// bridge holds the common parts of the HTTP handlers
+// that stream the elements of a Fanout to clients.
+// See NewSSEHandler and NewWebSocketHandler.
+type bridge[T any] struct {
+	fo        *Fanout[T]
+	enc       Encoder[T]
+	uuid      string
+	event     string
+	heartbeat time.Duration
+	origins   []string
+	binary    bool
+	subOpts   []SubscriberOpt[T]
+}
+
+// BridgeOpt represents a configuration option for the
+// HTTP handlers that stream the elements of a Fanout.
+// See implementations below.
+type BridgeOpt[T any] func(b *bridge[T])
+
+// WithBridgeHeartbeat sets the interval in which heartbeats
+// are sent to idle clients, so intermediate proxies do not
+// close the connection. Defaults to 15 seconds. Zero or
+// negative values disable them.
+func WithBridgeHeartbeat[T any](d time.Duration) BridgeOpt[T] {
+	return func(b *bridge[T]) {
+		b.heartbeat = d
+	}
+}
+
+// WithBridgeUUID sets the UUID of the subscribers created
+// for each one of the clients. See Fanout.SubscribeWith.
+func WithBridgeUUID[T any](uuid string) BridgeOpt[T] {
+	return func(b *bridge[T]) {
+		b.uuid = uuid
+	}
+}
+
+// WithBridgeEvent sets the event name of the Server-Sent
+// Events. If not set, clients will receive them as
+// "message" events. Its ignored by the WebSocket handler.
+func WithBridgeEvent[T any](name string) BridgeOpt[T] {
+	return func(b *bridge[T]) {
+		b.event = name
+	}
+}
+
+// WithBridgeOrigins allows WebSocket connections from the provided
+// origins, like "https://app.example.com", or from any one with "*".
+// By default, only the ones matching the Host of the request are
+// allowed, preventing cross-site WebSocket hijacking. Requests
+// without Origin, not coming from browsers, are always allowed.
+// Its ignored by the SSE handler, as browsers apply CORS to it.
+func WithBridgeOrigins[T any](origins ...string) BridgeOpt[T] {
+	return func(b *bridge[T]) {
+		b.origins = append(b.origins, origins...)
+	}
+}
+
+// WithBridgeBinary makes the WebSocket handler send the elements
+// as binary messages, instead of text ones. Its needed when the
+// Encoder output is not valid UTF-8, as clients must close the
+// connection on invalid text messages. Its ignored by the SSE handler.
+func WithBridgeBinary[T any]() BridgeOpt[T] {
+	return func(b *bridge[T]) {
+		b.binary = true
+	}
+}
+
+// WithBridgeSubscriberOpts sets the options for the subscribers
+// created for each one of the clients.
+func WithBridgeSubscriberOpts[T any](opts ...SubscriberOpt[T]) BridgeOpt[T] {
+	return func(b *bridge[T]) {
+		b.subOpts = append(b.subOpts, opts...)
+	}
+}
+
+func newBridge[T any](fo *Fanout[T], enc Encoder[T], opts ...BridgeOpt[T]) *bridge[T] {
+	b := &bridge[T]{
+		fo:        fo,
+		enc:       enc,
+		heartbeat: defaultBridgeHeartbeat,
+	}
+	if b.enc == nil {
+		b.enc = JSONCodec[T]{}
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// subscribe creates the subscription for a new client. The
+// provided options are applied after the configured ones.
+func (b *bridge[T]) subscribe(opts ...SubscriberOpt[T]) *Subscription[T] {
+	all := make([]SubscriberOpt[T], 0, len(b.subOpts)+len(opts))
+	all = append(all, b.subOpts...)
+	all = append(all, opts...)
+	return b.fo.NewSubscription(b.uuid, all...)
+}
+
+// allowedOrigin tells whether the Origin of the request
+// is allowed. See WithBridgeOrigins.
+func (b *bridge[T]) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range b.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// heartbeats returns a channel that ticks each heartbeat
+// interval, and a function for stopping it. If heartbeats
+// are disabled, the channel never ticks.
+func (b *bridge[T]) heartbeats() (<-chan time.Time, func()) {
+	if b.heartbeat <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(b.heartbeat)
+	return ticker.C, ticker.Stop
+}
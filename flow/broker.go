@@ -0,0 +1,308 @@
+package flow
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	topicSep          = "."
+	wildcardOne       = "*"
+	wildcardRemaining = ">"
+)
+
+// Message represents an element published in a Broker. It
+// carries the topic where it was published, so subscribers
+// of wildcard patterns can know its origin.
+type Message[T any] struct {
+	Topic string
+	Elem  T
+}
+
+// Broker manages named topics, each one backed by a Fanout.
+// Topics are lazily created on the first subscribe operation.
+// Publishing does not create them, so elements published in
+// a topic without subscribers, neither directly nor through
+// a pattern, are discarded.
+//
+// Topic names are composed by tokens separated by dots,
+// like "orders.created". Subscriptions accept patterns
+// with wildcards:
+//
+//   - "*" matches exactly one token. "orders.*" matches
+//     "orders.created", but not "orders.created.eu".
+//   - ">" matches one or more tokens, and can only be used
+//     as the last token. "orders.>" matches "orders.created"
+//     and "orders.created.eu".
+//
+// Each subscription pattern is backed by its own Fanout, which
+// will receive a copy of the elements published in all the
+// matching topics.
+//
+// This implements all the needed locking mechanisms,
+// so it can be considered thread safe.
+type Broker[T any] struct {
+	topics      map[string]*topic[T]
+	maxBuffLen  int
+	fanoutOpts  []FanoutOpt[Message[T]]
+	idleTimeout time.Duration
+	lastSweep   time.Time
+	l           sync.RWMutex
+}
+
+type topic[T any] struct {
+	fo           *Fanout[Message[T]]
+	wildcard     bool
+	lastActivity atomic.Int64
+}
+
+func (t *topic[T]) touch() {
+	t.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (t *topic[T]) idle(now time.Time, idleTimeout time.Duration) bool {
+	last := time.Unix(0, t.lastActivity.Load())
+	return now.Sub(last) >= idleTimeout && t.fo.ActiveSubscribers() == 0
+}
+
+// BrokerOpt represents a configuration option
+// for the Broker. See implementations below.
+type BrokerOpt[T any] func(b *Broker[T])
+
+// WithBrokerFanoutOpts sets the options that will be used
+// for creating the Fanout of each topic.
+func WithBrokerFanoutOpts[T any](opts ...FanoutOpt[Message[T]]) BrokerOpt[T] {
+	return func(b *Broker[T]) {
+		b.fanoutOpts = opts
+	}
+}
+
+// WithBrokerIdleTimeout enables the cleanup of idle topics. A
+// topic is considered idle when it has no active subscribers
+// and there was no publish or subscribe activity on it during
+// the provided timeout.
+//
+// The cleanup is opportunistically done during the publish
+// and subscribe operations. See also Broker.Cleanup.
+func WithBrokerIdleTimeout[T any](idleTimeout time.Duration) BrokerOpt[T] {
+	return func(b *Broker[T]) {
+		b.idleTimeout = idleTimeout
+	}
+}
+
+// NewBroker is the constructor for Broker. The maxBuffLen
+// will be used for the Fanout of each topic.
+func NewBroker[T any](maxBuffLen int, opts ...BrokerOpt[T]) *Broker[T] {
+	b := &Broker[T]{
+		topics:     make(map[string]*topic[T]),
+		maxBuffLen: maxBuffLen,
+		lastSweep:  time.Now(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Publish will send the elem to all the subscribers of the
+// provided topic, and also to all the subscribers of matching
+// wildcard patterns. The topic cannot contain wildcards. If so,
+// an ErrInvalidTopic will be returned.
+//
+// It returns the number of subscribers that dropped an
+// element during this call. See Fanout.Publish.
+func (b *Broker[T]) Publish(topicName string, elem T) (int, error) {
+	if !validTopic(topicName, false) {
+		return 0, ErrInvalidTopic
+	}
+	b.maybeCleanup()
+
+	b.l.RLock()
+	targets := b.targets(topicName)
+	b.l.RUnlock()
+
+	msg := Message[T]{Topic: topicName, Elem: elem}
+	var dropped int
+	for _, t := range targets {
+		t.touch()
+		// Topic fanouts are never closed.
+		n, _ := t.fo.Publish(msg)
+		dropped += n
+	}
+	return dropped, nil
+}
+
+// targets returns all the topics that should receive an element
+// published in the provided topic, this is, the topic itself, if
+// it exists, and all the matching patterns. Callers must hold
+// the lock.
+func (b *Broker[T]) targets(topicName string) (targets []*topic[T]) {
+	for name, t := range b.topics {
+		if name == topicName || (t.wildcard && matchTopic(name, topicName)) {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// Subscribe will return a ConsumerFunc and a CancelFunc for the
+// provided topic or pattern. See Fanout.Subscribe for more details.
+//
+// In case the pattern is not valid, an ErrInvalidTopic
+// will be returned.
+func (b *Broker[T]) Subscribe(pattern string, opts ...SubscriberOpt[Message[T]]) (ConsumerFunc[Message[T]], CancelFunc, error) { //nolint:gocritic
+	return b.SubscribeWith(pattern, "", opts...)
+}
+
+// SubscribeWith is same as Subscribe, but it allows to
+// customize the subscriber with an UUID. See Fanout.SubscribeWith.
+func (b *Broker[T]) SubscribeWith(pattern, uuid string, opts ...SubscriberOpt[Message[T]]) (ConsumerFunc[Message[T]], CancelFunc, error) { //nolint:gocritic
+	sub, err := b.NewSubscription(pattern, uuid, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub.Consume, sub.Cancel, nil
+}
+
+// NewSubscription is same as Fanout.NewSubscription, but
+// for the provided topic or pattern.
+//
+// In case the pattern is not valid, an ErrInvalidTopic
+// will be returned.
+func (b *Broker[T]) NewSubscription(pattern, uuid string, opts ...SubscriberOpt[Message[T]]) (*Subscription[Message[T]], error) {
+	if !validTopic(pattern, true) {
+		return nil, ErrInvalidTopic
+	}
+	b.maybeCleanup()
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	t := b.topic(pattern)
+	t.touch()
+	return t.fo.NewSubscription(uuid, opts...), nil
+}
+
+// topic returns the topic with the provided name or pattern,
+// creating it if it does not exist. Callers must hold the
+// write lock.
+func (b *Broker[T]) topic(name string) *topic[T] {
+	t, ok := b.topics[name]
+	if ok {
+		return t
+	}
+	t = &topic[T]{
+		fo:       NewFanout(b.maxBuffLen, b.fanoutOpts...),
+		wildcard: isWildcard(name),
+	}
+	t.touch()
+	b.topics[name] = t
+	return t
+}
+
+func (b *Broker[T]) maybeCleanup() {
+	if b.idleTimeout <= 0 {
+		return
+	}
+	b.l.RLock()
+	due := time.Since(b.lastSweep) >= b.idleTimeout
+	b.l.RUnlock()
+	if due {
+		b.Cleanup()
+	}
+}
+
+// Cleanup will remove all the idle topics. See WithBrokerIdleTimeout.
+// If no idle timeout was configured, only topics without active
+// subscribers are removed.
+//
+// It returns the number of removed topics.
+func (b *Broker[T]) Cleanup() int {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	now := time.Now()
+	b.lastSweep = now
+	var removed int
+	for name, t := range b.topics {
+		if t.idle(now, b.idleTimeout) {
+			delete(b.topics, name)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Topics returns the number of topics and patterns
+// currently managed by the Broker.
+func (b *Broker[T]) Topics() int {
+	b.l.RLock()
+	defer b.l.RUnlock()
+	return len(b.topics)
+}
+
+// Status will return the Status of each topic Fanout. As the key,
+// the topic name or the subscription pattern is used.
+func (b *Broker[T]) Status() map[string]Status {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	status := make(map[string]Status, len(b.topics))
+	for name, t := range b.topics {
+		status[name] = t.fo.Status()
+	}
+	return status
+}
+
+// validTopic checks the provided topic name. If wildcards
+// is true, it will also accept subscription patterns.
+func validTopic(name string, wildcards bool) bool {
+	if name == "" {
+		return false
+	}
+	tokens := strings.Split(name, topicSep)
+	for i, token := range tokens {
+		switch token {
+		case "":
+			return false
+		case wildcardOne:
+			if !wildcards {
+				return false
+			}
+		case wildcardRemaining:
+			if !wildcards || i != len(tokens)-1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isWildcard(pattern string) bool {
+	for _, token := range strings.Split(pattern, topicSep) {
+		if token == wildcardOne || token == wildcardRemaining {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTopic tells whether the provided topic
+// matches the subscription pattern.
+func matchTopic(pattern, topicName string) bool {
+	pTokens := strings.Split(pattern, topicSep)
+	tTokens := strings.Split(topicName, topicSep)
+	for i, pt := range pTokens {
+		if pt == wildcardRemaining {
+			return len(tTokens) > i
+		}
+		if i >= len(tTokens) {
+			return false
+		}
+		if pt != wildcardOne && pt != tTokens[i] {
+			return false
+		}
+	}
+	return len(pTokens) == len(tTokens)
+}
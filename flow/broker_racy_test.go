@@ -0,0 +1,81 @@
+//go:build racy
+
+package flow_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+// This is a racy test. See TestFanout_SupportsRace for more details.
+func TestBroker_SupportsRace(t *testing.T) {
+	ctx, testCancel := context.WithCancel(context.Background())
+
+	b := flow.NewBroker[int](20, flow.WithBrokerIdleTimeout[int](10*time.Millisecond))
+
+	var wg sync.WaitGroup
+
+	// Publish code path, with lazy topic creation.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				_, _ = b.Publish(fmt.Sprintf("orders.%d.created", i%10), i)
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+
+	// Subscribe, consume and cancel code paths.
+	for _, pattern := range []string{"orders.1.created", "orders.*.created", "orders.>"} {
+		pattern := pattern
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					sub, err := b.NewSubscription(pattern, "")
+					if err != nil {
+						panic(err)
+					}
+					for i := 0; i < 10; i++ {
+						_, _ = sub.ConsumeTimeout(time.Millisecond)
+					}
+					_ = sub.Cancel()
+				}
+			}
+		}()
+	}
+
+	// Status, cleanup and topics code paths.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				b.Status()
+				b.Cleanup()
+				b.Topics()
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}()
+
+	time.AfterFunc(5*time.Second, testCancel)
+	wg.Wait()
+}
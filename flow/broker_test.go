@@ -0,0 +1,133 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestBroker_Publish(t *testing.T) {
+	b := flow.NewBroker[int](10)
+
+	consumeCreated, _, err := b.Subscribe("orders.created")
+	require.NoError(t, err)
+	consumeDeleted, _, err := b.Subscribe("orders.deleted")
+	require.NoError(t, err)
+
+	_, err = b.Publish("orders.created", 1)
+	require.NoError(t, err)
+	_, err = b.Publish("orders.deleted", 2)
+	require.NoError(t, err)
+
+	assertConsumed(t, consumeCreated, flow.Message[int]{Topic: "orders.created", Elem: 1})
+	assertConsumed(t, consumeDeleted, flow.Message[int]{Topic: "orders.deleted", Elem: 2})
+}
+
+func TestBroker_Publish_NoSubscribers(t *testing.T) {
+	b := flow.NewBroker[int](10)
+	consume, cancel, err := b.Subscribe("orders.>")
+	require.NoError(t, err)
+	defer cancel()
+
+	for i := 0; i < 10; i++ {
+		_, err := b.Publish("orders."+strconv.Itoa(i), i)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, b.Topics(), "publishing must not create topics")
+
+	msg, err := consume()
+	require.NoError(t, err)
+	assert.Equal(t, "orders.0", msg.Elem.Topic)
+}
+
+func TestBroker_Publish_Wildcards(t *testing.T) {
+	b := flow.NewBroker[int](10)
+
+	consumeOne, _, err := b.Subscribe("orders.*")
+	require.NoError(t, err)
+	consumeRemaining, _, err := b.Subscribe("orders.>")
+	require.NoError(t, err)
+	consumeMiddle, _, err := b.Subscribe("orders.*.eu")
+	require.NoError(t, err)
+
+	for _, topic := range []string{"orders.created", "orders.created.eu", "orders", "users.created"} {
+		_, err = b.Publish(topic, 1)
+		require.NoError(t, err)
+	}
+
+	assertConsumed(t, consumeOne, flow.Message[int]{Topic: "orders.created", Elem: 1})
+	assertConsumed(t, consumeRemaining,
+		flow.Message[int]{Topic: "orders.created", Elem: 1},
+		flow.Message[int]{Topic: "orders.created.eu", Elem: 1},
+	)
+	assertConsumed(t, consumeMiddle, flow.Message[int]{Topic: "orders.created.eu", Elem: 1})
+
+	status := b.Status()
+	assert.Equal(t, flow.Status{"": 0}, status["orders.*"])
+	assert.Equal(t, flow.Status{"": 0}, status["orders.>"])
+	assert.NotContains(t, status, "users.created", "publishing must not create topics")
+}
+
+func TestBroker_InvalidTopics(t *testing.T) {
+	b := flow.NewBroker[int](10)
+
+	for _, topic := range []string{"", "orders.*", "orders.>", "orders..created", ".orders"} {
+		_, err := b.Publish(topic, 1)
+		assert.Equal(t, flow.ErrInvalidTopic, err, "publishing to %q", topic)
+	}
+	for _, pattern := range []string{"", "orders.>.created", "orders..created", "orders."} {
+		_, _, err := b.Subscribe(pattern)
+		assert.Equal(t, flow.ErrInvalidTopic, err, "subscribing to %q", pattern)
+	}
+}
+
+func TestBroker_Status(t *testing.T) {
+	b := flow.NewBroker[int](10)
+
+	_, _, err := b.SubscribeWith("orders.created", "a")
+	require.NoError(t, err)
+	_, _, err = b.SubscribeWith("orders.>", "b")
+	require.NoError(t, err)
+
+	_, _ = b.Publish("orders.created", 1)
+	_, _ = b.Publish("orders.deleted", 2)
+
+	want := map[string]flow.Status{
+		"orders.created": {"a": 1},
+		"orders.>":       {"b": 2},
+	}
+	assert.Equal(t, want, b.Status())
+}
+
+func TestBroker_Cleanup(t *testing.T) {
+	b := flow.NewBroker[int](10, flow.WithBrokerIdleTimeout[int](50*time.Millisecond))
+
+	_, cancel, err := b.Subscribe("orders.created")
+	require.NoError(t, err)
+	_, _, err = b.Subscribe("users.>")
+	require.NoError(t, err)
+	_, _ = b.Publish("orders.deleted", 1)
+
+	mustNoErr(cancel())
+	assert.Equal(t, 2, b.Topics(), "publishing must not create topics")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 1, b.Cleanup(), "only topics without subscribers should be removed")
+	assert.Equal(t, 1, b.Topics())
+
+	// Opportunistic cleanup
+	_, cancel, err = b.Subscribe("orders.created")
+	require.NoError(t, err)
+	mustNoErr(cancel())
+	time.Sleep(60 * time.Millisecond)
+	_, _ = b.Publish("users.created", 1)
+	assert.Equal(t, flow.Status{"": 1}, b.Status()["users.>"])
+	assert.NotContains(t, b.Status(), "orders.created")
+}
@@ -0,0 +1,49 @@
+package flow
+
+// WithSubscriberCoalesce makes the subscriber only keep the newest
+// element of each key, as returned by the provided function, while
+// its lagging. If an element with the same key is still queued when
+// a new one is published, the queued one is replaced in place, keeping
+// its position in the buffer. Useful for subscribers that only care
+// about the latest state of each entity.
+//
+// The key function is executed while holding the subscriber lock, so
+// it should be fast and must not call any Fanout method.
+func WithSubscriberCoalesce[T any](key func(elem T) string) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.coalesce = key
+		s.queued = make(map[string]*Slot[T])
+	}
+}
+
+// coalesced tries to replace the queued slot with the same key as
+// the provided one. If there is no such slot, it returns a private
+// copy of the provided one, already registered as queued, so it
+// can be replaced later. Callers must hold the subscriber lock.
+func (s *subscriber[T]) coalesced(sl *Slot[T]) (cp *Slot[T], replaced bool) {
+	k := s.coalesce(sl.Elem)
+	if q, ok := s.queued[k]; ok {
+		if s.lanes != nil && q.Priority != sl.Priority {
+			// It needs to move to the lane of its new priority.
+			s.unlane(q)
+			*q = *sl
+			s.enlane(q)
+		} else {
+			*q = *sl
+		}
+		s.coalescedCount.Add(1)
+		return q, true
+	}
+	c := *sl
+	s.queued[k] = &c
+	return &c, false
+}
+
+// forget unregisters the slot taken from the subscriber channel, so
+// it cannot be replaced anymore. Callers must hold the subscriber lock.
+func (s *subscriber[T]) forget(sl *Slot[T]) {
+	k := s.coalesce(sl.Elem)
+	if s.queued[k] == sl {
+		delete(s.queued, k)
+	}
+}
@@ -0,0 +1,36 @@
+package flow
+
+import (
+	"encoding/json"
+)
+
+// Codec represents the way elements of type T are
+// serialized, when they need to leave the memory of
+// the process. Like when they are stored on disk.
+type Codec[T any] interface {
+	Encode(elem T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// Encoder is the encoding part of a Codec. Its used when
+// elements only need to leave the process, like when they
+// are sent to HTTP clients. Any Codec satisfies it.
+type Encoder[T any] interface {
+	Encode(elem T) ([]byte, error)
+}
+
+// JSONCodec is a Codec that uses the encoding/json
+// package of the standard library.
+type JSONCodec[T any] struct{}
+
+// Encode implements the Codec interface.
+func (JSONCodec[T]) Encode(elem T) ([]byte, error) {
+	return json.Marshal(elem)
+}
+
+// Decode implements the Codec interface.
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var elem T
+	err := json.Unmarshal(data, &elem)
+	return elem, err
+}
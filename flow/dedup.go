@@ -0,0 +1,120 @@
+package flow
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// dedup remembers the keys of the recently seen elements. A key
+// is remembered for the configured window, counted from its first
+// occurrence, and only among the last size distinct keys, evicting
+// the least recently seen one. A zero value disables each limit.
+//
+// Its not thread safe. Callers must serialize the access.
+type dedup[T any] struct {
+	key    func(elem T) string
+	window time.Duration
+	size   int
+	keys   map[string]*dedupEntry
+	// seen keeps the entries in order of first occurrence, for
+	// the window, while recent keeps them in order of recency,
+	// for the size limit.
+	seen   *list.List
+	recent *list.List
+}
+
+type dedupEntry struct {
+	key       string
+	firstSeen time.Time
+	seen      *list.Element
+	recent    *list.Element
+}
+
+func newDedup[T any](key func(elem T) string, window time.Duration, size int) *dedup[T] {
+	return &dedup[T]{
+		key:    key,
+		window: window,
+		size:   size,
+		keys:   make(map[string]*dedupEntry),
+		seen:   list.New(),
+		recent: list.New(),
+	}
+}
+
+// isDuplicate tells whether the key of the provided element was
+// already seen. If not, the key is remembered from now on.
+func (d *dedup[T]) isDuplicate(elem T, now time.Time) bool {
+	d.purge(now)
+	k := d.key(elem)
+	if e, ok := d.keys[k]; ok {
+		d.recent.MoveToBack(e.recent)
+		return true
+	}
+	e := &dedupEntry{key: k, firstSeen: now}
+	e.seen = d.seen.PushBack(e)
+	e.recent = d.recent.PushBack(e)
+	d.keys[k] = e
+	if d.size > 0 && d.recent.Len() > d.size {
+		d.remove(d.recent.Front().Value.(*dedupEntry))
+	}
+	return false
+}
+
+// purge forgets the keys whose window already passed.
+func (d *dedup[T]) purge(now time.Time) {
+	if d.window <= 0 {
+		return
+	}
+	for e := d.seen.Front(); e != nil; e = d.seen.Front() {
+		entry := e.Value.(*dedupEntry)
+		if now.Sub(entry.firstSeen) < d.window {
+			return
+		}
+		d.remove(entry)
+	}
+}
+
+func (d *dedup[T]) remove(e *dedupEntry) {
+	delete(d.keys, e.key)
+	d.seen.Remove(e.seen)
+	d.recent.Remove(e.recent)
+}
+
+// WithFanoutDedup discards the published elements whose key, as
+// returned by the provided function, was already published within
+// the provided window, or among the last size distinct keys. A zero
+// value disables each limit, but at least one of them should be
+// provided, in order to bound the memory usage.
+//
+// Discarded elements never reach the subscribers. They are counted
+// as duplicated. See ExtendedStatus.
+func WithFanoutDedup[T any](key func(elem T) string, window time.Duration, size int) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.dedup = newDedup(key, window, size)
+	}
+}
+
+// Dedup only sends to the returned channel the elements of the input
+// channel whose key was not seen before, following the same rules
+// as WithFanoutDedup.
+func Dedup[T any](p *Pipeline, in <-chan T, key func(elem T) string, window time.Duration, size int) <-chan T {
+	out := make(chan T)
+	d := newDedup(key, window, size)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			if d.isDuplicate(elem, time.Now()) {
+				continue
+			}
+			if err := send(ctx, out, elem); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
@@ -0,0 +1,166 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+	"go.eloylp.dev/kit/moment"
+)
+
+type event struct {
+	Entity string
+	State  int
+}
+
+func eventKey(e event) string {
+	return e.Entity + ":" + strconv.Itoa(e.State)
+}
+
+func TestFanout_Dedup_Window(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[event](10,
+		flow.WithFanoutNowFunc[event](clock.Now),
+		flow.WithFanoutDedup(eventKey, time.Second, 0),
+	)
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublish(fo, event{"a", 1})
+	mustPublish(fo, event{"a", 1})
+	mustPublish(fo, event{"b", 1})
+	mustPublish(fo, event{"a", 2})
+	clock.Advance(500 * time.Millisecond)
+	mustPublish(fo, event{"a", 1})
+	clock.Advance(500 * time.Millisecond)
+	mustPublish(fo, event{"a", 1}) // Window from first occurrence passed.
+
+	assertConsumed(t, consume, event{"a", 1}, event{"b", 1}, event{"a", 2}, event{"a", 1})
+	assert.Equal(t, 0, fo.Status()[""])
+	status := fo.ExtendedStatus()
+	assert.Equal(t, uint64(4), status.Published)
+	assert.Equal(t, uint64(2), status.Duplicated)
+}
+
+func TestFanout_Dedup_Size(t *testing.T) {
+	fo := flow.NewFanout[event](10, flow.WithFanoutDedup(eventKey, 0, 2))
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublish(fo, event{"a", 1})
+	mustPublish(fo, event{"b", 1})
+	mustPublish(fo, event{"a", 1}) // Duplicated. "a" is now the most recent.
+	mustPublish(fo, event{"c", 1}) // Evicts "b", the least recently seen.
+	mustPublish(fo, event{"a", 1}) // Duplicated.
+	mustPublish(fo, event{"b", 1})
+
+	assertConsumed(t, consume, event{"a", 1}, event{"b", 1}, event{"c", 1}, event{"b", 1})
+	assert.Equal(t, 0, fo.Status()[""])
+	assert.Equal(t, uint64(2), fo.ExtendedStatus().Duplicated)
+}
+
+func TestFanout_Dedup_WindowAndSize(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[event](10,
+		flow.WithFanoutNowFunc[event](clock.Now),
+		flow.WithFanoutDedup(eventKey, time.Second, 10),
+	)
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublish(fo, event{"a", 1})
+	clock.Advance(500 * time.Millisecond)
+	mustPublish(fo, event{"b", 1})
+	clock.Advance(100 * time.Millisecond)
+	mustPublish(fo, event{"a", 1}) // Duplicated. "a" is now the most recent.
+	clock.Advance(600 * time.Millisecond)
+	mustPublish(fo, event{"a", 1}) // Window from first occurrence passed.
+	mustPublish(fo, event{"b", 1}) // Duplicated.
+
+	assertConsumed(t, consume, event{"a", 1}, event{"b", 1}, event{"a", 1})
+	assert.Equal(t, 0, fo.Status()[""])
+	assert.Equal(t, uint64(2), fo.ExtendedStatus().Duplicated)
+}
+
+func TestDedup(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+	in := flow.Dedup(p, source(p, 1, 2, 1, 3, 2, 4), strconv.Itoa, time.Minute, 10)
+
+	assert.Equal(t, []int{1, 2, 3, 4}, collect(in))
+	require.NoError(t, p.Wait())
+}
+
+func TestFanout_Coalesce(t *testing.T) {
+	fo := flow.NewFanout[event](10)
+	sub := fo.NewSubscription("a", flow.WithSubscriberCoalesce(func(e event) string {
+		return e.Entity
+	}))
+	defer sub.Cancel()
+
+	mustPublish(fo, event{"a", 1})
+	mustPublish(fo, event{"b", 1})
+	mustPublish(fo, event{"a", 2})
+	mustPublish(fo, event{"c", 1})
+	mustPublish(fo, event{"a", 3})
+	assert.Equal(t, 3, fo.Status()["a"])
+
+	slot, err := sub.Consume()
+	require.NoError(t, err)
+	assert.Equal(t, event{"a", 3}, slot.Elem, "queued element replaced in place")
+	assert.Equal(t, uint64(5), slot.ID)
+
+	// Once consumed, new elements of the same key are queued again.
+	mustPublish(fo, event{"a", 4})
+
+	for _, want := range []event{{"b", 1}, {"c", 1}, {"a", 4}} {
+		slot, err := sub.Consume()
+		require.NoError(t, err)
+		assert.Equal(t, want, slot.Elem)
+	}
+	assert.Equal(t, uint64(2), fo.ExtendedStatus().Subscribers["a"].Coalesced)
+}
+
+func TestFanout_Coalesce_DoesNotAffectOthers(t *testing.T) {
+	fo := flow.NewFanout[event](10)
+	coalesced := fo.NewSubscription("", flow.WithSubscriberCoalesce(func(e event) string {
+		return e.Entity
+	}))
+	defer coalesced.Cancel()
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublish(fo, event{"a", 1})
+	mustPublish(fo, event{"a", 2})
+
+	assertConsumed(t, consume, event{"a", 1}, event{"a", 2})
+	slot, err := coalesced.Consume()
+	require.NoError(t, err)
+	assert.Equal(t, event{"a", 2}, slot.Elem)
+}
+
+func TestFanout_Coalesce_DropOldest(t *testing.T) {
+	fo := flow.NewFanout[event](2)
+	sub := fo.NewSubscription("", flow.WithSubscriberCoalesce(func(e event) string {
+		return e.Entity
+	}))
+	defer sub.Cancel()
+
+	mustPublish(fo, event{"a", 1})
+	mustPublish(fo, event{"b", 1})
+	assert.Equal(t, 1, mustPublish(fo, event{"c", 1})) // Drops "a".
+	mustPublish(fo, event{"a", 2})                     // Drops "b".
+	mustPublish(fo, event{"a", 3})                     // Replaces "a".
+
+	for _, want := range []event{{"c", 1}, {"a", 3}} {
+		slot, err := sub.Consume()
+		require.NoError(t, err)
+		assert.Equal(t, want, slot.Elem)
+	}
+}
@@ -0,0 +1,538 @@
+package flow
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSegmentSize = 64 << 20
+	offsetsFile        = "offsets.json"
+)
+
+// Record represents a Slot stored in a DurableFanout,
+// along with its offset in the log.
+type Record[T any] struct {
+	Slot[T]
+	Offset uint64
+}
+
+// DurableFanout is a persistent variant of Fanout. All the
+// published elements are stored in an append only log on
+// local disk, split in segments. Elements are serialized
+// with the provided Codec.
+//
+// Subscribers are identified by their UUID, which is
+// mandatory here. Each subscriber can commit the offset of
+// the last processed Record, so it can resume from there
+// after a restart. Subscribers without a committed offset
+// will start from the oldest available Record.
+//
+// Old segments can be removed by size or age. See the
+// DurableOpt implementations.
+//
+// This implements all the needed locking mechanisms,
+// so it can be considered thread safe. Only one DurableFanout
+// should be opened per directory.
+type DurableFanout[T any] struct {
+	dir           string
+	codec         Codec[T]
+	segmentSize   int64
+	retentionSize int64
+	retentionAge  time.Duration
+	syncWrites    bool
+
+	segments []*segment
+	active   *os.File
+	next     uint64
+	offsets  map[string]uint64
+	ready    chan struct{}
+	closed   bool
+	l        sync.Mutex
+}
+
+// DurableOpt represents a configuration option for
+// the DurableFanout. See implementations below.
+type DurableOpt[T any] func(d *DurableFanout[T])
+
+// WithDurableSegmentSize sets the maximum size in bytes of each
+// segment of the log. Once reached, a new segment is created.
+// Defaults to 64MiB.
+func WithDurableSegmentSize[T any](size int64) DurableOpt[T] {
+	return func(d *DurableFanout[T]) {
+		d.segmentSize = size
+	}
+}
+
+// WithDurableRetentionSize will remove the oldest segments
+// once the total size in bytes of the log exceeds the provided one.
+// The active segment is never removed.
+func WithDurableRetentionSize[T any](size int64) DurableOpt[T] {
+	return func(d *DurableFanout[T]) {
+		d.retentionSize = size
+	}
+}
+
+// WithDurableRetentionAge will remove the segments which last
+// write is older than the provided age. The active segment is
+// never removed.
+func WithDurableRetentionAge[T any](age time.Duration) DurableOpt[T] {
+	return func(d *DurableFanout[T]) {
+		d.retentionAge = age
+	}
+}
+
+// WithDurableSync makes each publish operation to flush the
+// data to the disk before returning. Without this option, a
+// process crash will not lose data, but a system crash could.
+func WithDurableSync[T any]() DurableOpt[T] {
+	return func(d *DurableFanout[T]) {
+		d.syncWrites = true
+	}
+}
+
+// NewDurableFanout opens, or creates if not exists, a DurableFanout
+// in the provided directory. Previously stored data and committed
+// offsets will be loaded.
+//
+// If the last segment of the log contains an incomplete Record,
+// due to a crash, it will be truncated.
+func NewDurableFanout[T any](dir string, codec Codec[T], opts ...DurableOpt[T]) (*DurableFanout[T], error) {
+	d := &DurableFanout[T]{
+		dir:         dir,
+		codec:       codec,
+		segmentSize: defaultSegmentSize,
+		offsets:     make(map[string]uint64),
+		ready:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, fmt.Errorf("durable fanout: %w", err)
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, fmt.Errorf("durable fanout: %w", err)
+	}
+	d.segments = segments
+	if len(d.segments) == 0 {
+		if err := d.createSegment(0); err != nil {
+			return nil, err
+		}
+	} else {
+		last := d.segments[len(d.segments)-1]
+		records, err := recoverSegment(last)
+		if err != nil {
+			return nil, fmt.Errorf("durable fanout: %w", err)
+		}
+		d.next = last.base + records
+		d.active, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return nil, fmt.Errorf("durable fanout: %w", err)
+		}
+	}
+	if err := d.loadOffsets(); err != nil {
+		_ = d.active.Close()
+		return nil, err
+	}
+	d.applyRetention()
+	return d, nil
+}
+
+// Publish will append the elem to the log and notify all
+// the waiting subscribers. It returns the offset of the
+// new Record. Elements encoded in more than 64 MiB are
+// rejected with ErrRecordTooLarge.
+func (d *DurableFanout[T]) Publish(elem T) (uint64, error) {
+	payload, err := d.codec.Encode(elem)
+	if err != nil {
+		return 0, fmt.Errorf("durable fanout: encode: %w", err)
+	}
+	if len(payload) > recordMaxLen {
+		return 0, ErrRecordTooLarge
+	}
+	now := time.Now()
+	data := encodeRecord(now, payload)
+
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.closed {
+		return 0, ErrClosed
+	}
+	active := d.segments[len(d.segments)-1]
+	if active.size > 0 && active.size+int64(len(data)) > d.segmentSize {
+		if err := d.roll(); err != nil {
+			return 0, err
+		}
+		active = d.segments[len(d.segments)-1]
+	}
+	if _, err := d.active.Write(data); err != nil {
+		// Try to not leave an incomplete record behind.
+		_ = d.active.Truncate(active.size)
+		return 0, fmt.Errorf("durable fanout: %w", err)
+	}
+	if d.syncWrites {
+		if err := d.active.Sync(); err != nil {
+			return 0, fmt.Errorf("durable fanout: %w", err)
+		}
+	}
+	active.size += int64(len(data))
+	active.modTime = now
+	offset := d.next
+	d.next++
+	d.notify()
+	return offset, nil
+}
+
+// notify wakes up all the waiting subscribers.
+// Callers must hold the lock.
+func (d *DurableFanout[T]) notify() {
+	close(d.ready)
+	d.ready = make(chan struct{})
+}
+
+// roll closes the active segment, creates a new one and applies
+// the retention policies. Callers must hold the lock.
+func (d *DurableFanout[T]) roll() error {
+	if err := d.active.Close(); err != nil {
+		return fmt.Errorf("durable fanout: %w", err)
+	}
+	if err := d.createSegment(d.next); err != nil {
+		return err
+	}
+	d.applyRetention()
+	return nil
+}
+
+func (d *DurableFanout[T]) createSegment(base uint64) error {
+	path := segmentPath(d.dir, base)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0664)
+	if err != nil {
+		return fmt.Errorf("durable fanout: %w", err)
+	}
+	d.active = f
+	d.segments = append(d.segments, &segment{base: base, path: path, modTime: time.Now()})
+	return nil
+}
+
+// applyRetention removes the oldest segments exceeding the retention
+// policies. The active segment is never removed. Callers must hold
+// the lock.
+func (d *DurableFanout[T]) applyRetention() {
+	var total int64
+	for _, s := range d.segments {
+		total += s.size
+	}
+	now := time.Now()
+	for len(d.segments) > 1 {
+		oldest := d.segments[0]
+		exceedsSize := d.retentionSize > 0 && total > d.retentionSize
+		exceedsAge := d.retentionAge > 0 && now.Sub(oldest.modTime) > d.retentionAge
+		if !exceedsSize && !exceedsAge {
+			return
+		}
+		if err := os.Remove(oldest.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		total -= oldest.size
+		d.segments = d.segments[1:]
+	}
+}
+
+// segmentFor returns the segment that contains the provided
+// offset. If the offset is older than the oldest available one,
+// the oldest segment is returned along with its base offset.
+// Callers must hold the lock.
+func (d *DurableFanout[T]) segmentFor(offset uint64) (*segment, uint64) {
+	if offset < d.segments[0].base {
+		return d.segments[0], d.segments[0].base
+	}
+	i := sort.Search(len(d.segments), func(i int) bool {
+		return d.segments[i].base > offset
+	})
+	return d.segments[i-1], offset
+}
+
+func (d *DurableFanout[T]) loadOffsets() error {
+	data, err := os.ReadFile(filepath.Join(d.dir, offsetsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("durable fanout: %w", err)
+	}
+	if err := json.Unmarshal(data, &d.offsets); err != nil {
+		return fmt.Errorf("durable fanout: offsets: %w", err)
+	}
+	return nil
+}
+
+// storeOffsets atomically persists the committed offsets.
+// Callers must hold the lock.
+func (d *DurableFanout[T]) storeOffsets() error {
+	data, err := json.Marshal(d.offsets)
+	if err != nil {
+		return fmt.Errorf("durable fanout: offsets: %w", err)
+	}
+	path := filepath.Join(d.dir, offsetsFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0664); err != nil {
+		return fmt.Errorf("durable fanout: offsets: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("durable fanout: offsets: %w", err)
+	}
+	return nil
+}
+
+// NewSubscription registers a new subscriber with the provided UUID,
+// which cannot be empty. The subscriber will resume from its last
+// committed offset, if any.
+//
+// Its IMPORTANT to call DurableSubscription.Cancel once the subscriber
+// is no longer interested on consuming, in order to release resources.
+func (d *DurableFanout[T]) NewSubscription(uuid string) (*DurableSubscription[T], error) {
+	if uuid == "" {
+		return nil, ErrEmptyUUID
+	}
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	offset, ok := d.offsets[uuid]
+	if !ok {
+		offset = d.segments[0].base
+	}
+	return &DurableSubscription[T]{
+		d:      d,
+		uuid:   uuid,
+		offset: offset,
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Status will return a Status type with the pending elements
+// of all the subscribers that committed an offset.
+func (d *DurableFanout[T]) Status() Status {
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	status := make(Status, len(d.offsets))
+	oldest := d.segments[0].base
+	for uuid, offset := range d.offsets {
+		if offset < oldest {
+			offset = oldest
+		}
+		status[uuid] = int(d.next - offset)
+	}
+	return status
+}
+
+// Close will close the underlying log. All the subscribers
+// will receive an io.EOF and further operations will return
+// ErrClosed.
+func (d *DurableFanout[T]) Close() error {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	d.closed = true
+	d.notify()
+	if err := d.active.Close(); err != nil {
+		return fmt.Errorf("durable fanout: %w", err)
+	}
+	return nil
+}
+
+// DurableSubscription represents a registered subscriber
+// of a DurableFanout. It keeps its own reading position in
+// the log.
+//
+// Its methods should not be called concurrently.
+type DurableSubscription[T any] struct {
+	d        *DurableFanout[T]
+	uuid     string
+	offset   uint64
+	f        *os.File
+	r        *bufio.Reader
+	done     chan struct{}
+	doneOnce sync.Once
+	l        sync.Mutex
+}
+
+// Consume will block until a Record is available. In case
+// the subscription is cancelled or the DurableFanout closed,
+// an io.EOF error will be returned.
+func (s *DurableSubscription[T]) Consume() (*Record[T], error) {
+	return s.ConsumeContext(context.Background())
+}
+
+// ConsumeContext is same as Consume, but it will stop waiting
+// for records when the provided context ends. In such case,
+// ctx.Err() will be returned.
+func (s *DurableSubscription[T]) ConsumeContext(ctx context.Context) (*Record[T], error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.d.l.Lock()
+		if s.d.closed || s.cancelled() {
+			s.d.l.Unlock()
+			s.closeReader()
+			return nil, io.EOF
+		}
+		if s.offset < s.d.next {
+			s.d.l.Unlock()
+			return s.read()
+		}
+		ready := s.d.ready
+		s.d.l.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		case <-ready:
+		}
+	}
+}
+
+// read returns the Record at the current offset, which must exist.
+func (s *DurableSubscription[T]) read() (*Record[T], error) {
+	var opened bool
+	for {
+		if s.r == nil {
+			if err := s.open(); err != nil {
+				return nil, err
+			}
+			opened = true
+		}
+		ts, payload, err := readRecord(s.r)
+		if err == io.EOF && !opened {
+			// End of segment, lets continue with the next one.
+			s.closeReader()
+			continue
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			s.closeReader()
+			return nil, fmt.Errorf("durable fanout: offset %d: %w", s.offset, err)
+		}
+		offset := s.offset
+		s.offset++
+		elem, err := s.d.codec.Decode(payload)
+		if err != nil {
+			return nil, fmt.Errorf("durable fanout: decode offset %d: %w", offset, err)
+		}
+		return &Record[T]{
+			Slot: Slot[T]{
+				TimeStamp: ts,
+				Elem:      elem,
+			},
+			Offset: offset,
+		}, nil
+	}
+}
+
+// open opens the segment that contains the current offset and
+// positions the reader on it.
+func (s *DurableSubscription[T]) open() error {
+	for {
+		s.d.l.Lock()
+		seg, offset := s.d.segmentFor(s.offset)
+		s.d.l.Unlock()
+		s.offset = offset
+
+		f, err := os.Open(seg.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The segment was removed by the retention policies.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("durable fanout: %w", err)
+		}
+		r := bufio.NewReader(f)
+		if err := skipRecords(r, s.offset-seg.base); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("durable fanout: offset %d: %w", s.offset, err)
+		}
+		s.f, s.r = f, r
+		return nil
+	}
+}
+
+func (s *DurableSubscription[T]) closeReader() {
+	if s.f != nil {
+		_ = s.f.Close()
+	}
+	s.f, s.r = nil, nil
+}
+
+func (s *DurableSubscription[T]) cancelled() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Offset returns the offset of the next Record
+// to be consumed by this subscriber.
+func (s *DurableSubscription[T]) Offset() uint64 {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.offset
+}
+
+// Commit persists the offset of the last processed Record for
+// the subscriber UUID. After a restart, a subscriber with the same
+// UUID will resume from the next one. Offsets of not yet published
+// Records are rejected with ErrInvalidOffset.
+func (s *DurableSubscription[T]) Commit(offset uint64) error {
+	s.d.l.Lock()
+	defer s.d.l.Unlock()
+	if s.d.closed {
+		return ErrClosed
+	}
+	if offset >= s.d.next {
+		return ErrInvalidOffset
+	}
+	s.d.offsets[s.uuid] = offset + 1
+	return s.d.storeOffsets()
+}
+
+// Cancel terminates the subscription. Committed offsets are
+// preserved. Subsequent calls will return ErrSubscriberNotFound.
+func (s *DurableSubscription[T]) Cancel() error {
+	err := ErrSubscriberNotFound
+	s.doneOnce.Do(func() {
+		close(s.done)
+		err = nil
+	})
+	if err != nil {
+		return err
+	}
+	// Closing done wakes up any waiting consumer,
+	// so the lock will be released soon.
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.closeReader()
+	return nil
+}
@@ -0,0 +1,88 @@
+//go:build racy
+
+package flow_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+// This is a racy test. See TestFanout_SupportsRace for more details.
+func TestDurableFanout_SupportsRace(t *testing.T) {
+	ctx, testCancel := context.WithCancel(context.Background())
+
+	d, err := flow.NewDurableFanout[int](t.TempDir(), flow.JSONCodec[int]{},
+		flow.WithDurableSegmentSize[int](1024),
+		flow.WithDurableRetentionSize[int](4096),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	// Publish code path, with segment rolling and retention.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				_, _ = d.Publish(i)
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+
+	// Subscribe, consume, commit and cancel code paths.
+	for i := 0; i < 5; i++ {
+		uuid := fmt.Sprint(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				sub, err := d.NewSubscription(uuid)
+				if err != nil {
+					return
+				}
+				for j := 0; j < 100; j++ {
+					rec, err := sub.ConsumeContext(ctx)
+					if err != nil {
+						_ = sub.Cancel()
+						return
+					}
+					_ = sub.Commit(rec.Offset)
+				}
+				_ = sub.Cancel()
+			}
+		}()
+	}
+
+	// Status code path.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				d.Status()
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}()
+
+	time.AfterFunc(5*time.Second, func() {
+		testCancel()
+		_ = d.Close()
+	})
+	wg.Wait()
+}
@@ -0,0 +1,249 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestDurableFanout_PublishConsume(t *testing.T) {
+	d, err := flow.NewDurableFanout[string](t.TempDir(), flow.JSONCodec[string]{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	defer sub.Cancel()
+
+	for i, elem := range []string{"a", "b", "c"} {
+		offset, err := d.Publish(elem)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(i), offset)
+	}
+
+	assertConsumedRecords(t, sub, 0, "a", "b", "c")
+}
+
+func TestDurableFanout_ResumeFromCommittedOffset(t *testing.T) {
+	dir := t.TempDir()
+	d, err := flow.NewDurableFanout[int](dir, flow.JSONCodec[int]{})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := d.Publish(i)
+		require.NoError(t, err)
+	}
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	rec := assertConsumedRecords(t, sub, 0, 0, 1)
+	require.NoError(t, sub.Commit(rec.Offset))
+	require.NoError(t, sub.Cancel())
+	require.NoError(t, d.Close())
+
+	// Simulate a restart.
+	d, err = flow.NewDurableFanout[int](dir, flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	assert.Equal(t, flow.Status{"a": 3}, d.Status())
+
+	_, err = d.Publish(5)
+	require.NoError(t, err)
+
+	sub, err = d.NewSubscription("a")
+	require.NoError(t, err)
+	defer sub.Cancel()
+	assertConsumedRecords(t, sub, 2, 2, 3, 4, 5)
+
+	other, err := d.NewSubscription("b")
+	require.NoError(t, err)
+	defer other.Cancel()
+	assertConsumedRecords(t, other, 0, 0, 1)
+}
+
+func TestDurableFanout_SegmentsAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	d, err := flow.NewDurableFanout[int](dir, flow.JSONCodec[int]{},
+		flow.WithDurableSegmentSize[int](40),
+		flow.WithDurableRetentionSize[int](80),
+	)
+	require.NoError(t, err)
+	defer d.Close()
+
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	defer sub.Cancel()
+
+	// Each record takes 17 bytes, so 2 records per segment.
+	for i := 0; i < 10; i++ {
+		_, err := d.Publish(i)
+		require.NoError(t, err)
+		if i == 0 {
+			// Keep the first segment opened by the subscriber.
+			assertConsumedRecords(t, sub, 0, 0)
+		}
+	}
+	segments, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 3, "segments exceeding the retention size should be removed")
+
+	// The subscriber can finish the opened segment, then it jumps to
+	// the oldest available one.
+	assertConsumedRecords(t, sub, 1, 1)
+	assertConsumedRecords(t, sub, 4, 4, 5, 6, 7, 8, 9)
+
+	late, err := d.NewSubscription("b")
+	require.NoError(t, err)
+	defer late.Cancel()
+	assertConsumedRecords(t, late, 4, 4, 5, 6, 7, 8, 9)
+}
+
+func TestDurableFanout_RecoversIncompleteRecord(t *testing.T) {
+	dir := t.TempDir()
+	d, err := flow.NewDurableFanout[int](dir, flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	_, err = d.Publish(1)
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+
+	// Simulate a crash in the middle of a write.
+	f, err := os.OpenFile(filepath.Join(dir, "00000000000000000000.log"), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 10, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	d, err = flow.NewDurableFanout[int](dir, flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	offset, err := d.Publish(2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), offset)
+
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	defer sub.Cancel()
+	assertConsumedRecords(t, sub, 0, 1, 2)
+}
+
+func TestDurableFanout_RecoversCorruptedLength(t *testing.T) {
+	dir := t.TempDir()
+	d, err := flow.NewDurableFanout[int](dir, flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	_, err = d.Publish(1)
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+
+	// A complete header, with a huge length.
+	f, err := os.OpenFile(filepath.Join(dir, "00000000000000000000.log"), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	d, err = flow.NewDurableFanout[int](dir, flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	defer sub.Cancel()
+	assertConsumedRecords(t, sub, 0, 1)
+}
+
+func TestDurableFanout_CommitInvalidOffset(t *testing.T) {
+	d, err := flow.NewDurableFanout[int](t.TempDir(), flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	defer d.Close()
+	_, err = d.Publish(1)
+	require.NoError(t, err)
+
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	defer sub.Cancel()
+
+	assert.Equal(t, flow.ErrInvalidOffset, sub.Commit(1))
+	assert.Equal(t, flow.Status{}, d.Status())
+	require.NoError(t, sub.Commit(0))
+	assert.Equal(t, flow.Status{"a": 0}, d.Status())
+}
+
+func TestDurableFanout_ConsumeWaitsForPublish(t *testing.T) {
+	d, err := flow.NewDurableFanout[int](t.TempDir(), flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	defer sub.Cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = sub.ConsumeContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		_, _ = d.Publish(1)
+	})
+	assertConsumedRecords(t, sub, 0, 1)
+}
+
+func TestDurableFanout_CloseAndCancel(t *testing.T) {
+	d, err := flow.NewDurableFanout[int](t.TempDir(), flow.JSONCodec[int]{})
+	require.NoError(t, err)
+
+	sub, err := d.NewSubscription("a")
+	require.NoError(t, err)
+	require.NoError(t, sub.Cancel())
+	_, err = sub.Consume()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, flow.ErrSubscriberNotFound, sub.Cancel())
+
+	sub, err = d.NewSubscription("b")
+	require.NoError(t, err)
+	time.AfterFunc(50*time.Millisecond, func() {
+		_ = d.Close()
+	})
+	_, err = sub.Consume()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = d.Publish(1)
+	assert.Equal(t, flow.ErrClosed, err)
+	_, err = d.NewSubscription("c")
+	assert.Equal(t, flow.ErrClosed, err)
+}
+
+func TestDurableFanout_EmptyUUID(t *testing.T) {
+	d, err := flow.NewDurableFanout[int](t.TempDir(), flow.JSONCodec[int]{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	_, err = d.NewSubscription("")
+	assert.Equal(t, flow.ErrEmptyUUID, err)
+}
+
+func assertConsumedRecords[T any](t *testing.T, sub *flow.DurableSubscription[T], firstOffset uint64, want ...T) *flow.Record[T] {
+	t.Helper()
+	var rec *flow.Record[T]
+	for i, w := range want {
+		var err error
+		rec, err = sub.Consume()
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		assert.Equal(t, w, rec.Elem)
+		assert.Equal(t, firstOffset+uint64(i), rec.Offset)
+	}
+	return rec
+}
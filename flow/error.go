@@ -6,4 +6,15 @@ import (
 
 var (
 	ErrSubscriberNotFound = errors.New("fanout: subscriber not found")
+	ErrConsumeTimeout     = errors.New("fanout: consume timeout")
+	ErrInvalidTopic       = errors.New("broker: invalid topic or pattern")
+	ErrClosed             = errors.New("fanout: closed")
+	ErrNotClosed          = errors.New("fanout: not closed")
+	ErrDeliveryNotFound   = errors.New("fanout: delivery not found")
+	ErrRequestNotFound    = errors.New("fanout: request not found")
+	ErrInvalidOutputs     = errors.New("pipeline: invalid number of outputs")
+	ErrEmptyUUID          = errors.New("durable fanout: empty subscriber UUID")
+	ErrCorruptedRecord    = errors.New("durable fanout: corrupted record")
+	ErrRecordTooLarge     = errors.New("durable fanout: record too large")
+	ErrInvalidOffset      = errors.New("durable fanout: invalid offset")
 )
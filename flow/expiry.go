@@ -0,0 +1,54 @@
+package flow
+
+import (
+	"time"
+
+	"go.eloylp.dev/kit/moment"
+)
+
+// WithFanoutMaxAge sets the default max age of the elements for
+// all the subscribers of the Fanout. Elements older than that,
+// according to their Slot.TimeStamp, are skipped at consume time
+// and counted as expired. See ExtendedStatus.
+//
+// Subscribers can still override it with WithSubscriberMaxAge.
+func WithFanoutMaxAge[T any](maxAge time.Duration) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.maxAge = maxAge
+	}
+}
+
+// WithFanoutNowFunc sets the function used for getting the current
+// time, when assigning the Slot.TimeStamp and checking the expiry of
+// elements. Useful for testing. Defaults to time.Now.
+func WithFanoutNowFunc[T any](now moment.NowFunc) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.now = now
+	}
+}
+
+// WithSubscriberMaxAge sets the max age of the elements for the
+// subscriber, overriding the one of the Fanout. Zero disables it.
+// See WithFanoutMaxAge.
+func WithSubscriberMaxAge[T any](maxAge time.Duration) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.maxAge = maxAge
+	}
+}
+
+// isExpired tells whether the slot exceeds the subscriber max
+// age. If so, it will be counted as expired.
+func (s *subscriber[T]) isExpired(sl *Slot[T]) bool {
+	if s.maxAge <= 0 || s.now().Sub(sl.TimeStamp) <= s.maxAge {
+		return false
+	}
+	s.expired.Add(1)
+	if s.onExpire != nil {
+		s.onExpire()
+	}
+	return true
+}
+
+func (fo *Fanout[T]) countExpired() {
+	fo.expired.Add(1)
+}
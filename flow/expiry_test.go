@@ -0,0 +1,96 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/flow"
+	"go.eloylp.dev/kit/moment"
+)
+
+func TestFanout_MaxAge(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10,
+		flow.WithFanoutNowFunc[int](clock.Now),
+		flow.WithFanoutMaxAge[int](time.Second),
+	)
+	consume, _ := fo.SubscribeWith("a")
+
+	fo.Publish(1)
+	fo.Publish(2)
+	clock.Advance(2 * time.Second)
+	fo.Publish(3)
+
+	slot, err := consume()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, slot.Elem)
+	assert.Equal(t, clock.Now(), slot.TimeStamp)
+
+	status := fo.ExtendedStatus()
+	assert.Equal(t, uint64(2), status.Expired)
+	assert.Equal(t, uint64(2), status.Subscribers["a"].Expired)
+}
+
+func TestFanout_MaxAge_SubscriberOverride(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10,
+		flow.WithFanoutNowFunc[int](clock.Now),
+		flow.WithFanoutMaxAge[int](time.Second),
+	)
+	consume, _ := fo.Subscribe(flow.WithSubscriberMaxAge[int](0))
+
+	fo.Publish(1)
+	clock.Advance(time.Hour)
+
+	assertConsumed(t, consume, 1)
+	assert.Equal(t, uint64(0), fo.ExtendedStatus().Expired)
+}
+
+func TestFanout_MaxAge_PerSubscriber(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10, flow.WithFanoutNowFunc[int](clock.Now))
+	strict := fo.NewSubscription("strict", flow.WithSubscriberMaxAge[int](time.Second))
+	lax := fo.NewSubscription("lax")
+
+	fo.Publish(1)
+	clock.Advance(2 * time.Second)
+	fo.Publish(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	batch, err := strict.ConsumeBatch(ctx, 10, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, batchElems(batch))
+
+	batch, err = lax.ConsumeBatch(ctx, 10, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, batchElems(batch))
+
+	status := fo.ExtendedStatus()
+	assert.Equal(t, uint64(1), status.Expired)
+	assert.Equal(t, uint64(1), status.Subscribers["strict"].Expired)
+	assert.Equal(t, uint64(0), status.Subscribers["lax"].Expired)
+}
+
+func TestFanout_MaxAge_ConsumeTimeoutSkipsExpired(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10,
+		flow.WithFanoutNowFunc[int](clock.Now),
+		flow.WithFanoutMaxAge[int](time.Second),
+	)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	fo.Publish(1)
+	clock.Advance(2 * time.Second)
+
+	_, err := sub.ConsumeTimeout(10 * time.Millisecond)
+	assert.Equal(t, flow.ErrConsumeTimeout, err)
+	assert.Equal(t, uint64(1), fo.ExtendedStatus().Expired)
+}
@@ -1,16 +1,32 @@
 package flow
 
 import (
-	"io"
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.eloylp.dev/kit/moment"
 )
 
+// drainInterval is how often Drain checks
+// the queued elements of the subscribers.
+const drainInterval = 10 * time.Millisecond
+
 // Slot represents an enqueueable element. Timestamp
 // will allow consumers discard old elements. T will
 // represent the user custom data.
+//
+// ID is a sequence number assigned at publish time,
+// starting at 1. It allows consumers to resume from
+// a known point. See WithSubscriberReplayAfter.
+//
+// Priority is the one provided at publish time.
+// See PublishPriority.
 type Slot[T any] struct {
+	ID        uint64
 	TimeStamp time.Time
+	Priority  int
 	Elem      T
 }
 
@@ -38,86 +54,245 @@ type CancelFunc func() error
 // As the Key, the user provided subscriber UUID is used.
 // If not provided, an empty string will be used, aggregating
 // all counter values from all non customized consumers.
+// Consumer groups are also reported by their UUID, so
+// the Value is the pending count of the whole group.
 //
 // As the Value, the number of queued elements.
 type Status map[string]int
 
+// ExtendedStatus is an extended version of Status,
+// with more information about the activity of the
+// Fanout and its subscribers.
+type ExtendedStatus struct {
+	// Published is the total number of published elements.
+	Published uint64
+	// Dropped is the total number of discarded elements,
+	// among all subscribers, including the ones that are
+	// not subscribed anymore.
+	Dropped uint64
+	// Expired is the total number of skipped elements due
+	// to their age, among all subscribers. Same as Dropped,
+	// it includes the ones that are not subscribed anymore.
+	Expired uint64
+	// Duplicated is the total number of discarded
+	// elements at publish time, because they were
+	// already published. See WithFanoutDedup.
+	Duplicated uint64
+	// Subscribers follows the same aggregation rules
+	// as Status. The user provided subscriber UUID is
+	// used as the key.
+	Subscribers map[string]SubscriberStatus
+}
+
+// SubscriberStatus holds the counters of a subscriber,
+// or of a group of them with the same UUID.
+type SubscriberStatus struct {
+	// Pending is the number of queued elements.
+	Pending int
+	// Dropped is the number of discarded elements
+	// due to full buffers.
+	Dropped uint64
+	// Expired is the number of skipped elements
+	// due to their age. See WithFanoutMaxAge.
+	Expired uint64
+	// Coalesced is the number of queued elements that
+	// were replaced by a newer one with the same key.
+	// See WithSubscriberCoalesce.
+	Coalesced uint64
+	// Throttled is the number of discarded elements due
+	// to the rate limit. See WithSubscriberRateLimit.
+	Throttled uint64
+	// LastConsume is the moment of the last consume
+	// operation. In case of aggregation, the most
+	// recent one. Zero if there was no consumption.
+	LastConsume time.Time
+}
+
+// Drop represents an element that was discarded for
+// a subscriber, due to its full buffer. See DropHook.
+type Drop[T any] struct {
+	// UUID is the user provided UUID of the subscriber.
+	UUID string
+	// Slot is the discarded slot.
+	Slot *Slot[T]
+	// Dropped is the total number of discarded elements
+	// for the subscriber, including this one.
+	Dropped uint64
+	// Cancel terminates the subscription of the subscriber.
+	// Useful for disconnecting chronically slow ones.
+	Cancel CancelFunc
+}
+
+// DropHook is called each time an element is discarded
+// for a subscriber. Its executed in the publisher goroutine,
+// once all the internal locks are released. So its safe
+// to call Drop.Cancel from here.
+type DropHook[T any] func(d Drop[T])
+
 // Fanout represents a fan-out in-memory pattern
 // with a configurable buffer.
 //
 // It Will send a copy of the element to multiple
 // subscribers at the same time. In case a consumer
-// gets stalled, older elements will be discarded
-// in favour of the new arrived ones.
+// gets stalled, by default, older elements will be
+// discarded in favour of the new arrived ones. See
+// Policy for other behaviours.
 //
 // This implements all the needed locking mechanisms,
-// so it can be considered thread safe.
+// so it can be considered thread safe. Publishers are
+// serialized, but they iterate over a snapshot of the
+// subscribers, so subscribe and unsubscribe operations
+// do not need to wait for them.
 type Fanout[T any] struct {
-	subscribers []*subscriber[T]
+	subscribers registry[T]
+	groups      map[string]*group[T]
 	maxBuffLen  int
-	l           sync.RWMutex
+	policy      Policy
+	dropHook    DropHook[T]
+	consumeHook func(sl *Slot[T])
+	retention   retention
+	history     []*Slot[T]
+	maxAge      time.Duration
+	now         moment.NowFunc
+	dedup       *dedup[T]
+	priorities  int
+	codec       Codec[T]
+	restored    map[string][]*Slot[T]
+	handles     atomic.Uint64
+	published   atomic.Uint64
+	dropped     atomic.Uint64
+	expired     atomic.Uint64
+	duplicated  atomic.Uint64
+	restoring   atomic.Int64
+	closed      bool
+	done        chan struct{}
+	doneOnce    sync.Once
+	// l serializes publishers. It also protects the groups, the
+	// history, the dedup, the restored slots and the closed flag.
+	l sync.Mutex
 }
 
-type subscriber[T any] struct {
-	ch   chan *Slot[T]
-	uuid string
+// FanoutOpt represents a configuration option
+// for the Fanout. See implementations below.
+type FanoutOpt[T any] func(fo *Fanout[T])
+
+// WithFanoutPolicy sets the default Policy for all
+// the subscribers of the Fanout. Subscribers can still
+// override it with WithSubscriberPolicy.
+func WithFanoutPolicy[T any](p Policy) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.policy = p
+	}
+}
+
+// WithFanoutDropHook registers a DropHook, which will be
+// called each time an element is discarded for a subscriber.
+func WithFanoutDropHook[T any](h DropHook[T]) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.dropHook = h
+	}
 }
 
 // NewFanout is the constructor for BufferedFanOut.
-func NewFanout[T any](maxBuffLen int) *Fanout[T] {
+func NewFanout[T any](maxBuffLen int, opts ...FanoutOpt[T]) *Fanout[T] {
 	fo := &Fanout[T]{
 		maxBuffLen: maxBuffLen,
+		now:        time.Now,
+		codec:      JSONCodec[T]{},
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(fo)
 	}
 	return fo
 }
 
 // Publish will send an elem to all subscribers channels.
-// If one of the subscribers channels is full, oldest data
-// will be discarded.
-func (fo *Fanout[T]) Publish(elem T) {
-	fo.l.Lock()
-	defer fo.l.Unlock()
-	sl := &Slot[T]{
-		TimeStamp: time.Now(),
+// If one of the subscribers channels is full, the subscriber
+// Policy will be applied. By default, oldest data will be
+// discarded.
+//
+// It returns the number of subscribers that dropped an
+// element during this call. Once the Fanout is closed,
+// ErrClosed is returned.
+func (fo *Fanout[T]) Publish(elem T) (int, error) {
+	return fo.publishSlot(&Slot[T]{
+		TimeStamp: fo.now(),
 		Elem:      elem,
+	})
+}
+
+func (fo *Fanout[T]) publishSlot(sl *Slot[T]) (int, error) {
+	elem := sl.Elem
+	fo.l.Lock()
+	if fo.closed {
+		fo.l.Unlock()
+		return 0, ErrClosed
+	}
+	if fo.dedup != nil && fo.dedup.isDuplicate(elem, sl.TimeStamp) {
+		fo.l.Unlock()
+		fo.duplicated.Add(1)
+		return 0, nil
 	}
-	fo.publish(sl)
+	drops := fo.publish(sl)
+	fo.l.Unlock()
+
+	if fo.dropHook != nil {
+		for i := 0; i < len(drops); i++ {
+			fo.dropHook(drops[i])
+		}
+	}
+	return len(drops), nil
 }
 
-func (fo *Fanout[T]) publish(sl *Slot[T]) {
-	for i := 0; i < len(fo.subscribers); i++ {
-		if fo.subscribers[i] == nil {
-			continue
+func (fo *Fanout[T]) publish(sl *Slot[T]) []Drop[T] {
+	sl.ID = fo.published.Add(1)
+	var drops []Drop[T]
+	fo.subscribers.each(func(s *subscriber[T]) {
+		if s.grouped || !s.accepts(sl) {
+			return
 		}
-		if len(fo.subscribers[i].ch) == fo.maxBuffLen {
-			<-fo.subscribers[i].ch // remove last Slot of subscriber channel
+		drops = fo.deliver(s, sl, drops)
+	})
+	for _, g := range fo.groups {
+		if s := g.pick(sl); s != nil {
+			drops = fo.deliver(s, sl, drops)
 		}
-		fo.subscribers[i].ch <- sl
 	}
+	fo.retain(sl)
+	return drops
+}
+
+// deliver pushes the slot to the subscriber, registering
+// the discarded element, if any, in the provided drops.
+func (fo *Fanout[T]) deliver(s *subscriber[T], sl *Slot[T], drops []Drop[T]) []Drop[T] {
+	dropped := s.push(sl)
+	if dropped == nil {
+		return drops
+	}
+	fo.dropped.Add(1)
+	return append(drops, Drop[T]{
+		UUID:    s.uuid,
+		Slot:    dropped,
+		Dropped: s.dropped.Load(),
+		Cancel:  s.cancelFn,
+	})
 }
 
 // ActiveSubscribers will tell us how many subscribers
 // are registered and active in the present moment.
 func (fo *Fanout[T]) ActiveSubscribers() int {
-	fo.l.RLock()
-	defer fo.l.RUnlock()
-
-	var count int
-	for i := 0; i < len(fo.subscribers); i++ {
-		if fo.subscribers[i] != nil {
-			count++
-		}
-	}
-	return count
+	return fo.subscribers.activeLen()
 }
 
 // SubscribersLen returns the size of the underlying
 // subscriber storage. This will return both, active and
 // non active (free) subscriber slots.
+//
+// Free slots are reused by new subscribers. Once they are
+// the majority, the storage is compacted.
 func (fo *Fanout[T]) SubscribersLen() int {
-	fo.l.RLock()
-	defer fo.l.RUnlock()
-	return len(fo.subscribers)
+	return fo.subscribers.len()
 }
 
 // Subscribe will return two functions, a ConsumerFunc and
@@ -132,61 +307,108 @@ func (fo *Fanout[T]) SubscribersLen() int {
 // the consumer activity. If not, resources could be leaked. A
 // good practice here would be to always call the CancelFunc
 // in a defer statement.
-func (fo *Fanout[T]) Subscribe() (ConsumerFunc[T], CancelFunc) { //nolint:gocritic
-	return fo.SubscribeWith("")
+//
+// Optionally, SubscriberOpt options can be passed in order to
+// customize the subscriber behaviour.
+func (fo *Fanout[T]) Subscribe(opts ...SubscriberOpt[T]) (ConsumerFunc[T], CancelFunc) { //nolint:gocritic
+	return fo.SubscribeWith("", opts...)
 }
 
 // SubscribeWith is same as Subscribe, but it allows to
 // customize the subscriber with an UUID. Which might
 // unequivocally identify a subscriber or a group of them
 // in the system.
-func (fo *Fanout[T]) SubscribeWith(uuid string) (ConsumerFunc[T], CancelFunc) { //nolint:gocritic
-	fo.l.Lock()
-	defer fo.l.Unlock()
-	ch := make(chan *Slot[T], fo.maxBuffLen)
+func (fo *Fanout[T]) SubscribeWith(uuid string, opts ...SubscriberOpt[T]) (ConsumerFunc[T], CancelFunc) { //nolint:gocritic
+	sub := fo.NewSubscription(uuid, opts...)
+	return sub.Consume, sub.Cancel
+}
 
-	consumerFn := func() (*Slot[T], error) {
-		slot, ok := <-ch
-		if !ok {
-			return slot, io.EOF
-		}
-		return slot, nil
-	}
+// SubscribeChan is same as Subscribe, but it returns a receive
+// only channel instead of a ConsumerFunc. See Subscription.Chan.
+func (fo *Fanout[T]) SubscribeChan(opts ...SubscriberOpt[T]) (<-chan *Slot[T], CancelFunc) { //nolint:gocritic
+	sub := fo.NewSubscription("", opts...)
+	return sub.Chan(), sub.Cancel
+}
 
-	subscriber := &subscriber[T]{ch, uuid}
+// NewSubscription registers a new subscriber with the provided
+// UUID (it can be empty) and returns a *Subscription, which
+// offers more ways of consuming elements than the
+// ConsumerFunc returned by Subscribe and SubscribeWith.
+//
+// Same as with Subscribe, its IMPORTANT to call Subscription.Cancel
+// once the subscriber is no longer interested on consuming.
+//
+// Once the Fanout is closed, the returned Subscription is rejected.
+// All its consume operations and Cancel will return ErrClosed.
+func (fo *Fanout[T]) NewSubscription(uuid string, opts ...SubscriberOpt[T]) *Subscription[T] {
+	subscriber := &subscriber[T]{
+		handle:    fo.handles.Add(1),
+		uuid:      uuid,
+		createdAt: fo.now(),
+		buffLen:   fo.maxBuffLen,
+		lanes:     newLanes[T](fo.priorities),
+		policy:    fo.policy,
+		maxAge:    fo.maxAge,
+		now:       fo.now,
+		done:      make(chan struct{}),
+		stop:      fo.done,
+		onConsume: fo.consumeHook,
+		onExpire:  fo.countExpired,
+	}
+	for _, opt := range opts {
+		opt(subscriber)
+	}
+	subscriber.ch = make(chan *Slot[T], subscriber.buffLen)
+	// Publishers could see the subscriber as soon as its
+	// registered, so it needs to be complete before that.
+	subscriber.cancelFn = func() error {
+		return fo.unsubscribe(subscriber)
+	}
 
-	// Prefer reusing a free slot caused by a previous unsubscribe operation.
-	// Try to not increase underlying array too much. This is O(n) worst case.
-	for i := 0; i < len(fo.subscribers); i++ {
-		if fo.subscribers[i] == nil {
-			fo.subscribers[i] = subscriber
-			return consumerFn, func() error {
-				return fo.unsubscribe(i)
-			}
+	// Groups, replays and restores need to wait for any in
+	// flight publish operation. Plain subscribers do not.
+	restore := uuid != "" && fo.restoring.Load() > 0
+	if subscriber.grouped || subscriber.replay || restore {
+		fo.l.Lock()
+		defer fo.l.Unlock()
+	}
+	if !fo.subscribers.add(subscriber) {
+		subscriber.rejected = true
+		subscriber.cancelFn = func() error {
+			return ErrClosed
 		}
+		subscriber.close()
+		return &Subscription[T]{sub: subscriber}
 	}
-
-	// Looks like we are full of subscribers. Time to append more ...
-	fo.subscribers = append(fo.subscribers, subscriber)
-	index := len(fo.subscribers) - 1
-	return consumerFn, func() error {
-		return fo.unsubscribe(index)
+	if restore {
+		subscriber.restore(fo.takeRestored(uuid))
 	}
+	replay := subscriber.replay
+	if subscriber.grouped {
+		replay = fo.join(subscriber) && replay
+	}
+	if replay {
+		fo.replay(subscriber)
+	}
+	return &Subscription[T]{sub: subscriber}
 }
 
-func (fo *Fanout[T]) unsubscribe(index int) error {
-	fo.l.Lock()
-	defer fo.l.Unlock()
+func (fo *Fanout[T]) unsubscribe(s *subscriber[T]) error {
+	// Unblock any publisher waiting on this subscriber
+	// before acquiring the lock, as it could be holding it.
+	s.cancel()
 
-	if len(fo.subscribers) <= index {
-		return ErrSubscriberNotFound
+	if s.grouped {
+		fo.l.Lock()
+		defer fo.l.Unlock()
 	}
-	s := fo.subscribers[index]
-	if s == nil {
+	if !fo.subscribers.remove(s) {
 		return ErrSubscriberNotFound
 	}
-	close(s.ch)
-	fo.subscribers[index] = nil
+	s.close()
+	if s.grouped {
+		fo.leave(s)
+	}
 	return nil
 }
 
@@ -199,28 +421,108 @@ func (fo *Fanout[T]) unsubscribe(index int) error {
 func (fo *Fanout[T]) Reset() {
 	fo.l.Lock()
 	defer fo.l.Unlock()
-	for i := 0; i < len(fo.subscribers); i++ {
-		if fo.subscribers[i] == nil {
-			continue
+	for _, s := range fo.subscribers.reset() {
+		s.close()
+	}
+	fo.groups = nil
+	fo.history = nil
+}
+
+// Close terminates the Fanout. Further Publish operations
+// will return ErrClosed, as well as the consume operations of
+// further subscriptions. Closing an already closed Fanout also
+// returns ErrClosed.
+//
+// All the subscriber channels are closed, but subscribers can
+// still consume all the remaining elements, receiving io.EOF
+// afterwards. See Drain for waiting for them.
+//
+// Publishers waiting on stalled subscribers are unblocked, so
+// Close never waits for them. See the Block policy.
+func (fo *Fanout[T]) Close() error {
+	// Unblock any publisher waiting on a subscriber
+	// before acquiring the lock, as it could be holding it.
+	fo.doneOnce.Do(func() {
+		close(fo.done)
+	})
+	fo.l.Lock()
+	defer fo.l.Unlock()
+
+	if fo.closed {
+		return ErrClosed
+	}
+	fo.closed = true
+	for _, s := range fo.subscribers.close() {
+		s.close()
+	}
+	return nil
+}
+
+// Drain blocks until all the subscribers have consumed their
+// queued elements, or until the provided context ends. In the
+// latter case, ctx.Err() is returned.
+//
+// Its usually called after Close, so no new elements are
+// published in the meantime. Subscribers cancelling their
+// subscription are not waited for anymore.
+func (fo *Fanout[T]) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for {
+		if fo.pending() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
 		}
-		close(fo.subscribers[i].ch)
 	}
-	fo.subscribers = nil
+}
+
+// pending returns the number of queued elements
+// among all the registered subscribers.
+func (fo *Fanout[T]) pending() int {
+	var pending int
+	fo.subscribers.each(func(s *subscriber[T]) {
+		pending += len(s.ch)
+	})
+	return pending
 }
 
 // Status will return a Status type with
 // the list of all subscribers and their
 // pending elements.
 func (fo *Fanout[T]) Status() Status {
-	fo.l.RLock()
-	defer fo.l.RUnlock()
+	status := make(Status)
+	fo.subscribers.each(func(s *subscriber[T]) {
+		status[s.uuid] += len(s.ch)
+	})
+	return status
+}
 
-	status := make(Status, len(fo.subscribers))
-	for i := 0; i < len(fo.subscribers); i++ {
-		if fo.subscribers[i] == nil {
-			continue
-		}
-		status[fo.subscribers[i].uuid] += len(fo.subscribers[i].ch)
+// ExtendedStatus is same as Status, but it will return
+// an ExtendedStatus type, with the dropped elements and last
+// consume time of the subscribers, among other information.
+func (fo *Fanout[T]) ExtendedStatus() ExtendedStatus {
+	status := ExtendedStatus{
+		Published:   fo.published.Load(),
+		Dropped:     fo.dropped.Load(),
+		Expired:     fo.expired.Load(),
+		Duplicated:  fo.duplicated.Load(),
+		Subscribers: make(map[string]SubscriberStatus),
 	}
+	fo.subscribers.each(func(s *subscriber[T]) {
+		ss := status.Subscribers[s.uuid]
+		ss.Pending += len(s.ch)
+		ss.Dropped += s.dropped.Load()
+		ss.Expired += s.expired.Load()
+		ss.Coalesced += s.coalescedCount.Load()
+		ss.Throttled += s.throttled.Load()
+		if lc := s.lastConsumeTime(); lc.After(ss.LastConsume) {
+			ss.LastConsume = lc
+		}
+		status.Subscribers[s.uuid] = ss
+	})
 	return status
 }
@@ -1,6 +1,7 @@
 package flow_test
 
 import (
+	"sync"
 	"testing"
 
 	"go.eloylp.dev/kit/flow"
@@ -43,3 +44,73 @@ func BenchmarkBufferedFanOut_AddItem_100000_50_10000(b *testing.B) {
 func BenchmarkBufferedFanOut_AddItem_1000000_50_10000(b *testing.B) {
 	FanoutAddElem(b, 1000000, 50, 10000)
 }
+
+// FanoutSubscribeWhilePublishing measures the subscribe and cancel
+// operations while another goroutine is continuously publishing to
+// the provided number of subscribers.
+func FanoutSubscribeWhilePublishing(b *testing.B, subscribers, maxBuffLen, msgLen int) {
+	b.ReportAllocs()
+
+	fo := flow.NewFanout[[]byte](maxBuffLen)
+	for i := 0; i < subscribers; i++ {
+		fo.Subscribe()
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		data := make([]byte, msgLen)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				fo.Publish(data)
+			}
+		}
+	}()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, cancel := fo.Subscribe()
+		_ = cancel()
+	}
+	b.StopTimer()
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkBufferedFanOut_SubscribeWhilePublishing_1000_50_10000(b *testing.B) {
+	FanoutSubscribeWhilePublishing(b, 1000, 50, 10000)
+}
+
+func BenchmarkBufferedFanOut_SubscribeWhilePublishing_10000_50_10000(b *testing.B) {
+	FanoutSubscribeWhilePublishing(b, 10000, 50, 10000)
+}
+
+// FanoutSubscribe measures the subscribe and cancel operations
+// when there are already many subscribers, some of them cancelled.
+func FanoutSubscribe(b *testing.B, subscribers int) {
+	b.ReportAllocs()
+
+	fo := flow.NewFanout[[]byte](50)
+	for i := 0; i < subscribers; i++ {
+		_, cancel := fo.Subscribe()
+		if i%4 == 0 {
+			_ = cancel()
+		}
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, cancel := fo.Subscribe()
+		_ = cancel()
+	}
+}
+
+func BenchmarkBufferedFanOut_Subscribe_1000(b *testing.B) {
+	FanoutSubscribe(b, 1000)
+}
+
+func BenchmarkBufferedFanOut_Subscribe_100000(b *testing.B) {
+	FanoutSubscribe(b, 100000)
+}
@@ -3,11 +3,22 @@
 package flow_test
 
 import (
+	"bufio"
 	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"go.eloylp.dev/kit/flow"
 )
 
@@ -25,7 +36,14 @@ func TestFanout_SupportsRace(t *testing.T) {
 
 	ctx, testCancel := context.WithCancel(context.Background())
 
-	fo := flow.NewFanout[int](20)
+	reg := prometheus.NewRegistry()
+	collector := flow.NewFanoutCollector[int]("racy", prometheus.DefBuckets)
+	reg.MustRegister(collector)
+
+	fo := flow.NewFanout[int](20, flow.WithFanoutDropHook(func(d flow.Drop[int]) {
+		_ = d.Dropped
+	}), flow.WithFanoutCollector(collector), flow.WithFanoutRetention[int](10, time.Second),
+		flow.WithFanoutPriorities[int](3))
 
 	var wg sync.WaitGroup
 
@@ -40,6 +58,8 @@ func TestFanout_SupportsRace(t *testing.T) {
 			default:
 				time.Sleep(600 * time.Millisecond)
 				fo.Status()
+				fo.ExtendedStatus()
+				_, _ = reg.Gather()
 			}
 		}
 	}()
@@ -49,6 +69,43 @@ func TestFanout_SupportsRace(t *testing.T) {
 	// Subscriber code path (subscribes and consumes)
 	subscribersVector(ctx, &wg, fo, cancels)
 
+	// Subscription code path (subscribes and consumes with context)
+	subscriptionsVector(ctx, &wg, fo)
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberPolicy[int](flow.DropNewest()))
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberPolicy[int](flow.BlockTimeout(time.Millisecond)))
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberReplay[int]())
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberMaxAge[int](time.Microsecond))
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberRateLimit[int](time.Microsecond, 3))
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberBuffLen[int](3), flow.WithSubscriberCoalesce(func(elem int) string {
+		return strconv.Itoa(elem % 5)
+	}))
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberGroup[int](flow.RoundRobin))
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberGroup[int](flow.LeastLoaded))
+	subscriptionsVector(ctx, &wg, fo, flow.WithSubscriberBuffLen[int](5), flow.WithSubscriberFilter(func(elem int) bool {
+		return elem%2 == 0
+	}))
+
+	// Channel subscribers code path
+	chanSubscribersVector(ctx, &wg, fo)
+
+	// Batch consumption code path
+	batchSubscriptionsVector(ctx, &wg, fo)
+
+	// Acknowledged consumption code path (redeliveries and dead-letter)
+	ackSubscriptionsVector(ctx, &wg, fo)
+
+	// Subscribers churn code path (storage growth and compaction)
+	churnVector(ctx, &wg, fo)
+
+	// Unix socket server and clients code path (with reconnections)
+	socketVector(ctx, &wg, fo, t.TempDir())
+
+	// Introspection and forced unsubscribe code path
+	adminVector(ctx, &wg, fo)
+
+	// HTTP bridges code path (clients connecting and disconnecting)
+	bridgesVector(ctx, &wg, fo)
+
 	// Unsubscribe code path
 	wg.Add(1)
 	go func() {
@@ -64,20 +121,23 @@ func TestFanout_SupportsRace(t *testing.T) {
 		}
 	}()
 
-	// Add elem code path
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				fo.Publish(1)
-				time.Sleep(100 * time.Microsecond)
+	// Add elem code path (concurrent publishers, with priorities)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					fo.Publish(1)
+					fo.PublishPriority(2, rand.Intn(3))
+					time.Sleep(100 * time.Microsecond)
+				}
 			}
-		}
-	}()
+		}()
+	}
 
 	// Add reset code path
 	wg.Add(1)
@@ -120,6 +180,12 @@ func TestFanout_SupportsRace(t *testing.T) {
 
 	// Enough ! lets stop all things
 	time.AfterFunc(10*time.Second, func() {
+		// Close and drain code path, while subscriptions
+		// are still being requested.
+		_ = fo.Close()
+		drainCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_ = fo.Drain(drainCtx)
 		fo.Reset()
 		testCancel()
 	})
@@ -146,3 +212,275 @@ func subscribersVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.
 		}()
 	}
 }
+
+func chanSubscribersVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T]) {
+	for i := 0; i < 10; i++ {
+		ch, cancel := fo.SubscribeChan()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					_ = cancel()
+					for range ch {
+					}
+					return
+				case <-ch:
+				}
+			}
+		}()
+	}
+}
+
+func batchSubscriptionsVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T]) {
+	for i := 0; i < 10; i++ {
+		sub := fo.NewSubscription("")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sub.Cancel()
+			for {
+				if _, err := sub.ConsumeBatch(ctx, 5, time.Millisecond); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func ackSubscriptionsVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T]) {
+	dlq := flow.NewFanout[T](10)
+	for i := 0; i < 10; i++ {
+		sub := fo.NewAckSubscription("",
+			flow.WithAckVisibilityTimeout[T](time.Millisecond),
+			flow.WithAckMaxAttempts[T](3),
+			flow.WithAckDeadLetter(dlq),
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sub.Cancel()
+			for {
+				d, err := sub.ConsumeContext(ctx)
+				if err != nil {
+					return
+				}
+				switch rand.Intn(3) {
+				case 0:
+					_ = d.Ack()
+				case 1:
+					_ = d.Nack()
+				}
+			}
+		}()
+	}
+}
+
+func subscriptionsVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T], opts ...flow.SubscriberOpt[T]) {
+	for i := 0; i < 10; i++ {
+		sub := fo.NewSubscription("", opts...)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sub.Cancel()
+			for {
+				if _, err := sub.ConsumeContext(ctx); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func churnVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T]) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cancels := make([]flow.CancelFunc, 0, 200)
+		for {
+			select {
+			case <-ctx.Done():
+				for _, cancel := range cancels {
+					_ = cancel()
+				}
+				return
+			default:
+				for len(cancels) < 200 {
+					_, cancel := fo.Subscribe()
+					cancels = append(cancels, cancel)
+				}
+				// Cancel most of them, in order to trigger a compaction.
+				for _, cancel := range cancels[:180] {
+					_ = cancel()
+				}
+				cancels = append(cancels[:0], cancels[180:]...)
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+}
+
+func socketVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T], dir string) {
+	path := filepath.Join(dir, "fanout.sock")
+	codec := flow.JSONCodec[T]{}
+	// The server is restarted from time to time, so
+	// clients need to reconnect.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			srv := flow.NewSocketServer[T](fo, codec)
+			go srv.ListenAndServe(path) //nolint:errcheck
+			time.Sleep(100 * time.Millisecond)
+			_ = srv.Close()
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				consume, cancel, err := flow.SubscribeSocket[T](path, codec,
+					flow.WithSocketClientReconnect[T](time.Millisecond),
+					flow.WithSocketClientBuffLen[T](rand.Intn(3)),
+				)
+				if err != nil {
+					time.Sleep(time.Millisecond)
+					continue
+				}
+				go func() {
+					time.Sleep(time.Duration(rand.Intn(200)) * time.Millisecond)
+					_ = cancel()
+				}()
+				for {
+					if _, err := consume(); err != nil {
+						break
+					}
+				}
+			}
+		}()
+	}
+}
+
+func adminVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T]) {
+	h := flow.NewAdminHandler(fo)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			for i := 0; i < 5; i++ {
+				fo.NewSubscription("admin-handle")
+				fo.NewSubscription("admin-uuid")
+			}
+			for _, info := range fo.Subscribers() {
+				if info.UUID == "admin-handle" {
+					_ = fo.Unsubscribe(info.Handle)
+				}
+			}
+			_, _ = fo.UnsubscribeUUID("admin-uuid")
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+}
+
+func bridgesVector[T any](ctx context.Context, wg *sync.WaitGroup, fo *flow.Fanout[T]) {
+	sse := httptest.NewServer(flow.NewSSEHandler[T](fo, nil, flow.WithBridgeHeartbeat[T](time.Millisecond)))
+	ws := httptest.NewServer(flow.NewWebSocketHandler[T](fo, nil, flow.WithBridgeHeartbeat[T](time.Millisecond)))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		sse.Close()
+		ws.Close()
+	}()
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				sseClient(ctx, sse.URL)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				wsClient(ws.URL)
+			}
+		}()
+	}
+}
+
+// sseClient reads some lines of the stream, then disconnects.
+func sseClient(ctx context.Context, url string) {
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	s := bufio.NewScanner(resp.Body)
+	for i := 0; i < 20; i++ {
+		if !s.Scan() {
+			return
+		}
+	}
+}
+
+// wsClient reads some frames, then disconnects.
+func wsClient(url string) {
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(url, "http://"), 100*time.Millisecond)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _ = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	_, _ = io.CopyN(io.Discard, conn, 1024)
+}
+
+// This is a racy test. See TestFanout_SupportsRace for more details.
+// Replaying a big history takes time, so forced unsubscribes could
+// happen while the subscriber channel is being filled.
+func TestFanout_UnsubscribeReplaying_SupportsRace(t *testing.T) {
+	ctx, testCancel := context.WithCancel(context.Background())
+
+	const historyLen = 100_000
+	fo := flow.NewFanout[int](historyLen, flow.WithFanoutRetention[int](historyLen, 0))
+	for i := 0; i < historyLen; i++ {
+		_, _ = fo.Publish(i)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				sub := fo.NewSubscription("replay", flow.WithSubscriberReplay[int]())
+				_ = sub.Cancel()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			for _, info := range fo.Subscribers() {
+				_ = fo.Unsubscribe(info.Handle)
+			}
+			_, _ = fo.UnsubscribeUUID("replay")
+		}
+	}()
+
+	time.AfterFunc(5*time.Second, testCancel)
+	wg.Wait()
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"go.eloylp.dev/kit/flow"
+	"go.eloylp.dev/kit/moment"
 )
 
 func TestFanout_Subscribe_ElementsAreSentToSubscribers(t *testing.T) {
@@ -156,6 +157,142 @@ func TestFanout_Status_Unsubscribe(t *testing.T) {
 	assert.Equal(t, want, fo.Status())
 }
 
+func TestFanout_Close(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	consume, cancel := fo.Subscribe()
+	mustPublish(fo, 1)
+	mustPublish(fo, 2)
+
+	assert.NoError(t, fo.Close())
+	assert.Equal(t, flow.ErrClosed, fo.Close())
+
+	_, err := fo.Publish(3)
+	assert.Equal(t, flow.ErrClosed, err)
+
+	// Already subscribed consumers can still consume the remaining elements.
+	assertConsumed(t, consume, 1, 2)
+	_, err = consume()
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, cancel())
+
+	// New subscriptions are rejected.
+	consume, cancel = fo.Subscribe()
+	_, err = consume()
+	assert.Equal(t, flow.ErrClosed, err)
+	assert.Equal(t, flow.ErrClosed, cancel())
+	assert.Equal(t, 0, fo.ActiveSubscribers())
+
+	sub := fo.NewSubscription("", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	_, err = sub.ConsumeContext(context.Background())
+	assert.Equal(t, flow.ErrClosed, err)
+	_, ok := <-sub.Chan()
+	assert.False(t, ok)
+}
+
+func TestFanout_Close_BlockedPublisher(t *testing.T) {
+	var hooked []flow.Drop[int]
+	fo := flow.NewFanout[int](1,
+		flow.WithFanoutPolicy[int](flow.Block()),
+		flow.WithFanoutDropHook(func(d flow.Drop[int]) {
+			hooked = append(hooked, d)
+		}),
+	)
+	consume, _ := fo.Subscribe()
+	mustPublish(fo, 1)
+
+	type result struct {
+		n   int
+		err error
+	}
+	published := make(chan result, 1)
+	go func() {
+		n, err := fo.Publish(2)
+		published <- result{n, err}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- fo.Close()
+	}()
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("close must not wait for the blocked publisher")
+	}
+	res := <-published
+	assert.NoError(t, res.err)
+	assert.Equal(t, 1, res.n, "the aborted element counts as dropped")
+	assert.Equal(t, uint64(1), fo.ExtendedStatus().Dropped)
+	if assert.Len(t, hooked, 1) {
+		assert.Equal(t, 2, hooked[0].Slot.Elem)
+	}
+
+	assertConsumed(t, consume, 1)
+	_, err := consume()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFanout_Drain(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	consume, _ := fo.Subscribe()
+	_, cancel := fo.Subscribe()
+	for i := 1; i <= 3; i++ {
+		mustPublish(fo, i)
+	}
+	mustNoErr(fo.Close())
+
+	// Cancelled subscribers are not waited for.
+	mustNoErr(cancel())
+
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		for {
+			time.Sleep(10 * time.Millisecond)
+			if _, err := consume(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second)
+	defer ctxCancel()
+	assert.NoError(t, fo.Drain(ctx))
+	assert.Equal(t, flow.Status{"": 0}, fo.Status())
+	<-consumed
+}
+
+func TestFanout_Drain_ContextEnds(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	_, _ = fo.Subscribe()
+	mustPublish(fo, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, fo.Drain(ctx))
+}
+
+func TestFanout_SlotIDs(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	for i := 1; i <= 3; i++ {
+		fo.Publish(i)
+	}
+	for i := 1; i <= 3; i++ {
+		slot, err := sub.Consume()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), slot.ID)
+	}
+}
+
 func TestFanout_SubscribersStoreReuse(t *testing.T) {
 	fo := flow.NewFanout[int](10)
 
@@ -181,3 +318,136 @@ func TestFanout_SubscribersStoreGrows(t *testing.T) {
 
 	assert.Equal(t, 3, fo.SubscribersLen(), "Subscriber len should grow linearly")
 }
+
+func TestFanout_SubscribersStoreCompaction(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	cancels := make([]flow.CancelFunc, 100)
+	for i := 0; i < 100; i++ {
+		_, cancels[i] = fo.Subscribe()
+	}
+	consume, cancel := fo.SubscribeWith("last")
+
+	for i := 0; i < 60; i++ {
+		mustNoErr(cancels[i]())
+	}
+	// The storage is compacted once the free slots are the majority (51),
+	// then it keeps the following free slots for reuse.
+	assert.Equal(t, 50, fo.SubscribersLen(), "Free slots should be compacted once they are the majority")
+	assert.Equal(t, 41, fo.ActiveSubscribers())
+
+	fo.Publish(1)
+	assertConsumed(t, consume, 1)
+
+	// Moved subscribers can still be cancelled.
+	assert.NoError(t, cancel())
+	assert.Equal(t, flow.ErrSubscriberNotFound, cancel())
+	for i := 60; i < 100; i++ {
+		assert.NoError(t, cancels[i]())
+	}
+	assert.Equal(t, 0, fo.ActiveSubscribers())
+}
+
+func TestFanout_ExtendedStatus(t *testing.T) {
+	fo := flow.NewFanout[int](1)
+
+	_, _ = fo.SubscribeWith("a")
+	consume, _ := fo.SubscribeWith("b")
+
+	fo.Publish(1)
+	fo.Publish(2)
+	before := time.Now()
+	consume()
+
+	status := fo.ExtendedStatus()
+	assert.Equal(t, uint64(2), status.Published)
+	assert.Equal(t, 1, status.Subscribers["a"].Pending)
+	assert.Equal(t, uint64(1), status.Subscribers["a"].Dropped)
+	assert.True(t, status.Subscribers["a"].LastConsume.IsZero())
+	assert.Equal(t, 0, status.Subscribers["b"].Pending)
+	assert.Equal(t, uint64(1), status.Subscribers["b"].Dropped)
+	assert.False(t, status.Subscribers["b"].LastConsume.Before(before))
+}
+
+func TestFanout_ExtendedStatus_NowFunc(t *testing.T) {
+	now := moment.NewFakedNow(t, "2021-01-01 00:00:00")
+	fo := flow.NewFanout[int](1, flow.WithFanoutNowFunc[int](now))
+	consume, _ := fo.SubscribeWith("a")
+
+	mustPublish(fo, 1)
+	assertConsumed(t, consume, 1)
+
+	assert.True(t, now().Equal(fo.ExtendedStatus().Subscribers["a"].LastConsume))
+}
+
+func TestFanout_ExtendedStatus_Aggregated(t *testing.T) {
+	fo := flow.NewFanout[int](1)
+
+	_, _ = fo.Subscribe()
+	_, _ = fo.Subscribe()
+
+	fo.Publish(1)
+	fo.Publish(2)
+
+	want := flow.SubscriberStatus{Pending: 2, Dropped: 2}
+	assert.Equal(t, want, fo.ExtendedStatus().Subscribers[""])
+}
+
+func TestFanout_DropHook(t *testing.T) {
+	var drops []flow.Drop[int]
+	fo := flow.NewFanout[int](1, flow.WithFanoutDropHook(func(d flow.Drop[int]) {
+		drops = append(drops, d)
+		if d.Dropped == 2 {
+			// Disconnect the chronically slow subscriber.
+			mustNoErr(d.Cancel())
+		}
+	}))
+
+	_, _ = fo.SubscribeWith("slow")
+
+	fo.Publish(1)
+	fo.Publish(2)
+	fo.Publish(3)
+	fo.Publish(4)
+
+	if assert.Len(t, drops, 2) {
+		assert.Equal(t, "slow", drops[0].UUID)
+		assert.Equal(t, 1, drops[0].Slot.Elem)
+		assert.Equal(t, uint64(1), drops[0].Dropped)
+		assert.Equal(t, 2, drops[1].Slot.Elem)
+		assert.Equal(t, uint64(2), drops[1].Dropped)
+	}
+	assert.Equal(t, 0, fo.ActiveSubscribers())
+}
+
+func TestFanout_Subscribe_CustomBuffLen(t *testing.T) {
+	fo := flow.NewFanout[int](1)
+
+	_, _ = fo.SubscribeWith("dashboard", flow.WithSubscriberBuffLen[int](3))
+	_, _ = fo.SubscribeWith("default")
+
+	fo.Publish(1)
+	fo.Publish(2)
+	fo.Publish(3)
+
+	want := flow.Status{
+		"dashboard": 3,
+		"default":   1,
+	}
+	assert.Equal(t, want, fo.Status())
+}
+
+func TestFanout_Subscribe_Filter(t *testing.T) {
+	fo := flow.NewFanout[int](2)
+
+	critical := func(elem int) bool { return elem > 10 }
+	consume, _ := fo.SubscribeWith("alerts", flow.WithSubscriberFilter(critical))
+
+	fo.Publish(11)
+	fo.Publish(1)
+	fo.Publish(2)
+	assert.Equal(t, 0, mustPublish(fo, 12), "filtered out elements should not occupy buffer space")
+
+	assert.Equal(t, flow.Status{"alerts": 2}, fo.Status())
+	assertConsumed(t, consume, 11, 12)
+}
@@ -0,0 +1,102 @@
+package flow
+
+// Balance determines how the elements are distributed
+// among the members of a consumer group. See WithSubscriberGroup.
+type Balance int
+
+const (
+	// RoundRobin delivers each element to the next
+	// member of the group, in turns.
+	RoundRobin Balance = iota
+	// LeastLoaded delivers each element to the member
+	// of the group with less queued elements.
+	LeastLoaded
+)
+
+// group represents a set of subscribers that split
+// the stream of elements among them.
+type group[T any] struct {
+	balance Balance
+	members []*subscriber[T]
+	next    int
+}
+
+// WithSubscriberGroup makes the subscriber join the consumer
+// group identified by its UUID. Members of the same group split
+// the stream of elements among them, following the provided
+// Balance strategy, instead of each one receiving a copy. This
+// provides work queue semantics.
+//
+// Different groups, as well as subscribers without group, still
+// receive a full copy of the stream. The Balance strategy of the
+// group is established by the member that creates it.
+//
+// The retained history, if requested with WithSubscriberReplay,
+// is only replayed to the member that creates the group.
+func WithSubscriberGroup[T any](balance Balance) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.grouped = true
+		s.balance = balance
+	}
+}
+
+// pick returns the member of the group that should receive
+// the slot. Only members whose filter accepts the slot are
+// considered. It returns nil if there is no candidate.
+func (g *group[T]) pick(sl *Slot[T]) *subscriber[T] {
+	var picked *subscriber[T]
+	start := g.next
+	for i := 0; i < len(g.members); i++ {
+		idx := (start + i) % len(g.members)
+		m := g.members[idx]
+		if !m.accepts(sl) {
+			continue
+		}
+		if picked == nil {
+			picked = m
+			g.next = idx + 1
+			if g.balance == RoundRobin {
+				break
+			}
+			continue
+		}
+		if len(m.ch) < len(picked.ch) {
+			picked = m
+		}
+	}
+	return picked
+}
+
+// join adds the subscriber to its group, creating it if
+// needed. It returns true if the group was created.
+// Callers must hold the Fanout lock.
+func (fo *Fanout[T]) join(s *subscriber[T]) bool {
+	if fo.groups == nil {
+		fo.groups = make(map[string]*group[T])
+	}
+	g, ok := fo.groups[s.uuid]
+	if !ok {
+		g = &group[T]{balance: s.balance}
+		fo.groups[s.uuid] = g
+	}
+	g.members = append(g.members, s)
+	return !ok
+}
+
+// leave removes the subscriber from its group, removing
+// the group if it gets empty. Callers must hold the Fanout lock.
+func (fo *Fanout[T]) leave(s *subscriber[T]) {
+	g, ok := fo.groups[s.uuid]
+	if !ok {
+		return
+	}
+	for i := 0; i < len(g.members); i++ {
+		if g.members[i] == s {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	if len(g.members) == 0 {
+		delete(fo.groups, s.uuid)
+	}
+}
@@ -0,0 +1,104 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestFanout_Group_RoundRobin(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	consume1, _ := fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	consume2, _ := fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	consumeCopy, _ := fo.SubscribeWith("audit")
+
+	for i := 1; i <= 4; i++ {
+		fo.Publish(i)
+	}
+
+	assertConsumed(t, consume1, 1, 3)
+	assertConsumed(t, consume2, 2, 4)
+	assertConsumed(t, consumeCopy, 1, 2, 3, 4)
+}
+
+func TestFanout_Group_LeastLoaded(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	consume1, _ := fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.LeastLoaded))
+	_, _ = fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.LeastLoaded))
+
+	for i := 1; i <= 4; i++ {
+		fo.Publish(i)
+	}
+	assertConsumed(t, consume1, 1, 3) // Now the first member is the least loaded one.
+	fo.Publish(5)
+	fo.Publish(6)
+
+	assertConsumed(t, consume1, 5, 6)
+	assert.Equal(t, flow.Status{"workers": 2}, fo.Status())
+}
+
+func TestFanout_Group_Status(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	_, _ = fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	_, _ = fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	_, _ = fo.SubscribeWith("reporting", flow.WithSubscriberGroup[int](flow.RoundRobin))
+
+	for i := 1; i <= 3; i++ {
+		fo.Publish(i)
+	}
+
+	want := flow.Status{
+		"workers":   3,
+		"reporting": 3,
+	}
+	assert.Equal(t, want, fo.Status())
+}
+
+func TestFanout_Group_Unsubscribe(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	_, cancel1 := fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.RoundRobin))
+	consume2, _ := fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.RoundRobin))
+
+	mustNoErr(cancel1())
+	fo.Publish(1)
+	fo.Publish(2)
+
+	assertConsumed(t, consume2, 1, 2)
+}
+
+func TestFanout_Group_Filter(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+
+	even := func(elem int) bool { return elem%2 == 0 }
+	consumeEven, _ := fo.SubscribeWith("workers",
+		flow.WithSubscriberGroup[int](flow.RoundRobin), flow.WithSubscriberFilter(even))
+	consumeAll, _ := fo.SubscribeWith("workers", flow.WithSubscriberGroup[int](flow.RoundRobin))
+
+	fo.Publish(1)
+	fo.Publish(2)
+	fo.Publish(3)
+
+	assertConsumed(t, consumeEven, 2)
+	assertConsumed(t, consumeAll, 1, 3)
+}
+
+func TestFanout_Group_ReplayOnlyOnce(t *testing.T) {
+	fo := flow.NewFanout[int](10, flow.WithFanoutRetention[int](10, 0))
+
+	fo.Publish(1)
+
+	opts := []flow.SubscriberOpt[int]{flow.WithSubscriberGroup[int](flow.RoundRobin), flow.WithSubscriberReplay[int]()}
+	consume1, _ := fo.SubscribeWith("workers", opts...)
+	_, _ = fo.SubscribeWith("workers", opts...)
+
+	assertConsumed(t, consume1, 1)
+	assert.Equal(t, flow.Status{"workers": 0}, fo.Status())
+}
@@ -1,7 +1,34 @@
 package flow_test
 
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/flow"
+)
+
 func mustNoErr(err error) {
 	if err != nil {
 		panic(err)
 	}
 }
+
+func assertConsumed[T any](t *testing.T, consume flow.ConsumerFunc[T], want ...T) {
+	t.Helper()
+	for _, w := range want {
+		slot, err := consume()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, w, slot.Elem)
+	}
+}
+
+// mustPublish publishes the element, returning the
+// number of drops. It panics if the Fanout is closed.
+func mustPublish[T any](fo *flow.Fanout[T], elem T) int {
+	drops, err := fo.Publish(elem)
+	mustNoErr(err)
+	return drops
+}
@@ -0,0 +1,108 @@
+package flow
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FanoutCollector is a prometheus.Collector that exports
+// the activity of a named Fanout. It should be attached to
+// the Fanout with WithFanoutCollector and then registered in
+// a Prometheus registry.
+//
+// All the metrics carry a "fanout" label with the provided
+// name, so multiple Fanouts can be registered in the same
+// registry. The exported metrics are:
+//
+//   - fanout_active_subscribers (gauge)
+//   - fanout_queued_elements (gauge), per subscriber UUID.
+//   - fanout_published_total (counter)
+//   - fanout_dropped_total (counter)
+//   - fanout_expired_total (counter)
+//   - fanout_duplicated_total (counter)
+//   - fanout_consume_latency_seconds (histogram), the time
+//     elapsed since the Slot.TimeStamp till consumption.
+type FanoutCollector[T any] struct {
+	fo *Fanout[T]
+
+	activeSubscribers *prometheus.Desc
+	queuedElements    *prometheus.Desc
+	published         *prometheus.Desc
+	dropped           *prometheus.Desc
+	expired           *prometheus.Desc
+	duplicated        *prometheus.Desc
+	consumeLatency    prometheus.Histogram
+}
+
+// NewFanoutCollector creates a new FanoutCollector for the Fanout
+// with the provided name. The consume latency histogram will use
+// the provided buckets.
+func NewFanoutCollector[T any](name string, buckets []float64) *FanoutCollector[T] {
+	labels := prometheus.Labels{"fanout": name}
+	return &FanoutCollector[T]{
+		activeSubscribers: prometheus.NewDesc("fanout_active_subscribers",
+			"Number of active subscribers.", nil, labels),
+		queuedElements: prometheus.NewDesc("fanout_queued_elements",
+			"Number of queued elements per subscriber UUID.", []string{"uuid"}, labels),
+		published: prometheus.NewDesc("fanout_published_total",
+			"Total number of published elements.", nil, labels),
+		dropped: prometheus.NewDesc("fanout_dropped_total",
+			"Total number of discarded elements due to full subscriber buffers.", nil, labels),
+		expired: prometheus.NewDesc("fanout_expired_total",
+			"Total number of skipped elements due to their age.", nil, labels),
+		duplicated: prometheus.NewDesc("fanout_duplicated_total",
+			"Total number of discarded elements at publish time, as they were already published.", nil, labels),
+		consumeLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Subsystem:   "fanout",
+			Name:        "consume_latency_seconds",
+			Help:        "Time elapsed since an element was published till it was consumed.",
+			Buckets:     buckets,
+			ConstLabels: labels,
+		}),
+	}
+}
+
+// WithFanoutCollector attaches the provided FanoutCollector
+// to the Fanout. A FanoutCollector should only be attached
+// to one Fanout.
+func WithFanoutCollector[T any](c *FanoutCollector[T]) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		c.fo = fo
+		fo.consumeHook = c.observe
+	}
+}
+
+// observe measures the consume latency with the clock of the
+// Fanout, the same one used for the Slot.TimeStamp. Its read
+// at consume time, as WithFanoutNowFunc could come later in
+// the options.
+func (c *FanoutCollector[T]) observe(sl *Slot[T]) {
+	c.consumeLatency.Observe(c.fo.now().Sub(sl.TimeStamp).Seconds())
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *FanoutCollector[T]) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.activeSubscribers
+	ch <- c.queuedElements
+	ch <- c.published
+	ch <- c.dropped
+	ch <- c.expired
+	ch <- c.duplicated
+	c.consumeLatency.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *FanoutCollector[T]) Collect(ch chan<- prometheus.Metric) {
+	c.consumeLatency.Collect(ch)
+	if c.fo == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.activeSubscribers, prometheus.GaugeValue, float64(c.fo.ActiveSubscribers()))
+	status := c.fo.ExtendedStatus()
+	ch <- prometheus.MustNewConstMetric(c.published, prometheus.CounterValue, float64(status.Published))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(status.Dropped))
+	ch <- prometheus.MustNewConstMetric(c.expired, prometheus.CounterValue, float64(status.Expired))
+	ch <- prometheus.MustNewConstMetric(c.duplicated, prometheus.CounterValue, float64(status.Duplicated))
+	for uuid, ss := range status.Subscribers {
+		ch <- prometheus.MustNewConstMetric(c.queuedElements, prometheus.GaugeValue, float64(ss.Pending), uuid)
+	}
+}
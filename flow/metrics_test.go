@@ -0,0 +1,76 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+	"go.eloylp.dev/kit/moment"
+)
+
+func TestFanoutCollector(t *testing.T) {
+	// Prepare prometheus registry
+	reg := prometheus.NewRegistry()
+	collector := flow.NewFanoutCollector[int]("orders", []float64{0.05, 0.2})
+	reg.MustRegister(collector)
+
+	fo := flow.NewFanout[int](1, flow.WithFanoutCollector(collector))
+	_, _ = fo.SubscribeWith("a")
+	consume, _ := fo.SubscribeWith("b")
+
+	fo.Publish(1)
+	fo.Publish(2)
+	consume()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	ph := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+	ph.ServeHTTP(rec, req)
+
+	respMetrics, err := ioutil.ReadAll(rec.Body)
+	require.NoError(t, err)
+	metrics := string(respMetrics)
+
+	assert.Contains(t, metrics, `fanout_active_subscribers{fanout="orders"} 2`)
+	assert.Contains(t, metrics, `fanout_queued_elements{fanout="orders",uuid="a"} 1`)
+	assert.Contains(t, metrics, `fanout_queued_elements{fanout="orders",uuid="b"} 0`)
+	assert.Contains(t, metrics, `fanout_published_total{fanout="orders"} 2`)
+	assert.Contains(t, metrics, `fanout_dropped_total{fanout="orders"} 2`)
+	assert.Contains(t, metrics, `fanout_expired_total{fanout="orders"} 0`)
+	assert.Contains(t, metrics, `fanout_duplicated_total{fanout="orders"} 0`)
+	assert.Contains(t, metrics, `# TYPE fanout_consume_latency_seconds histogram`)
+}
+
+func TestFanoutCollector_NowFunc(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	collector := flow.NewFanoutCollector[int]("orders", []float64{0.05, 0.2})
+	reg.MustRegister(collector)
+
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](1,
+		flow.WithFanoutCollector(collector),
+		flow.WithFanoutNowFunc[int](clock.Now),
+	)
+	consume, _ := fo.Subscribe()
+
+	fo.Publish(1)
+	clock.Advance(100 * time.Millisecond)
+	consume()
+
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	metrics := rec.Body.String()
+
+	assert.Contains(t, metrics, `fanout_consume_latency_seconds_bucket{fanout="orders",le="0.05"} 0`)
+	assert.Contains(t, metrics, `fanout_consume_latency_seconds_bucket{fanout="orders",le="0.2"} 1`)
+}
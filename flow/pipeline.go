@@ -0,0 +1,422 @@
+package flow
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"sync"
+	"time"
+)
+
+// Pipeline coordinates a set of stages, each one running in its
+// own goroutine and connected to the others by channels. Stages
+// are created with the operators below, like Map or Filter,
+// which can be chained, as each one consumes the output channel
+// of the previous one.
+//
+// The first error returned by a stage cancels the context of the
+// whole Pipeline, so all the stages stop. Stages always close
+// their output channels before finishing, so downstream stages
+// finish too once upstream ones are exhausted.
+//
+// Its IMPORTANT to call Wait, in order to know when all the
+// stages are done and the possible error.
+type Pipeline struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// NewPipeline creates a Pipeline, whose stages will stop
+// once the provided context ends.
+func NewPipeline(ctx context.Context) *Pipeline {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Pipeline{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Context returns the context of the Pipeline. It ends
+// once a stage fails or the parent context ends.
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+// Go runs the provided function as a stage of the Pipeline.
+// Useful for custom stages. The function should return once
+// the provided context ends. If it returns an error, the whole
+// Pipeline is cancelled.
+func (p *Pipeline) Go(fn func(ctx context.Context) error) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := fn(p.ctx); err != nil {
+			p.errOnce.Do(func() {
+				p.err = err
+				p.cancel()
+			})
+		}
+	}()
+}
+
+// Wait blocks until all the stages are done. It returns the
+// first error returned by any of them. If the parent context
+// ended, its error is returned.
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	p.cancel()
+	return p.err
+}
+
+// send tries to send the element to the channel,
+// till the provided context ends.
+func send[T any](ctx context.Context, out chan<- T, elem T) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case out <- elem:
+		return nil
+	}
+}
+
+// receive tries to receive an element from the channel, till
+// the provided context ends. It returns false if the channel was
+// closed or the context ended. In the latter case, ctx.Err() is
+// also returned.
+func receive[T any](ctx context.Context, in <-chan T) (elem T, ok bool, err error) {
+	select {
+	case <-ctx.Done():
+		return elem, false, ctx.Err()
+	case elem, ok = <-in:
+		return elem, ok, nil
+	}
+}
+
+// Map applies the provided function to each element of the input
+// channel, sending the results to the returned channel. An error
+// returned by the function cancels the Pipeline.
+func Map[T, U any](p *Pipeline, in <-chan T, fn func(ctx context.Context, elem T) (U, error)) <-chan U {
+	out := make(chan U)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			result, err := fn(ctx, elem)
+			if err != nil {
+				return err
+			}
+			if err := send(ctx, out, result); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
+
+// Filter only sends to the returned channel the elements of the
+// input channel for which the provided predicate returns true.
+func Filter[T any](p *Pipeline, in <-chan T, fn func(elem T) bool) <-chan T {
+	out := make(chan T)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			if !fn(elem) {
+				continue
+			}
+			if err := send(ctx, out, elem); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
+
+// FlatMap is same as Map, but the provided function can return
+// any number of results per element. They are sent in order.
+func FlatMap[T, U any](p *Pipeline, in <-chan T, fn func(ctx context.Context, elem T) ([]U, error)) <-chan U {
+	out := make(chan U)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			results, err := fn(ctx, elem)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < len(results); i++ {
+				if err := send(ctx, out, results[i]); err != nil {
+					return err
+				}
+			}
+		}
+	})
+	return out
+}
+
+// Merge sends the elements of all the input channels to the
+// returned one (fan-in). Ordering among different inputs is
+// not guaranteed. The returned channel is closed once all
+// the inputs are closed.
+func Merge[T any](p *Pipeline, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	for _, in := range ins {
+		in := in
+		p.Go(func(ctx context.Context) error {
+			defer wg.Done()
+			for {
+				elem, ok, err := receive(ctx, in)
+				if !ok {
+					return err
+				}
+				if err := send(ctx, out, elem); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	p.Go(func(context.Context) error {
+		wg.Wait()
+		close(out)
+		return nil
+	})
+	return out
+}
+
+// Partition splits the input channel in n output channels. Elements
+// with the same key are always sent to the same output, so their
+// relative order is kept. A stalled output blocks the rest.
+//
+// If n is not positive, no outputs are returned and the
+// Pipeline fails with ErrInvalidOutputs.
+func Partition[T any](p *Pipeline, in <-chan T, n int, key func(elem T) string) []<-chan T {
+	if !validOutputs(p, n) {
+		return nil
+	}
+	outs := make([]chan T, n)
+	for i := 0; i < n; i++ {
+		outs[i] = make(chan T)
+	}
+	p.Go(func(ctx context.Context) error {
+		defer closeAll(outs)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(key(elem)))
+			if err := send(ctx, outs[h.Sum32()%uint32(n)], elem); err != nil {
+				return err
+			}
+		}
+	})
+	return receiveOnly(outs)
+}
+
+// Tee sends each element of the input channel to all the n output
+// channels. An element is not sent to any output till the previous
+// one was received by all of them, so a stalled output blocks
+// the rest. See Buffer for mitigating this.
+//
+// Same as Partition, if n is not positive, no outputs are
+// returned and the Pipeline fails with ErrInvalidOutputs.
+func Tee[T any](p *Pipeline, in <-chan T, n int) []<-chan T {
+	if !validOutputs(p, n) {
+		return nil
+	}
+	outs := make([]chan T, n)
+	for i := 0; i < n; i++ {
+		outs[i] = make(chan T)
+	}
+	p.Go(func(ctx context.Context) error {
+		defer closeAll(outs)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				if err := send(ctx, outs[i], elem); err != nil {
+					return err
+				}
+			}
+		}
+	})
+	return receiveOnly(outs)
+}
+
+// validOutputs tells whether n is a valid number of
+// outputs. If not, it makes the Pipeline fail.
+func validOutputs(p *Pipeline, n int) bool {
+	if n > 0 {
+		return true
+	}
+	p.Go(func(ctx context.Context) error {
+		return ErrInvalidOutputs
+	})
+	return false
+}
+
+// Buffer returns a channel with the provided buffer size, which
+// receives the elements of the input channel. This decouples the
+// speed of the upstream stages from the downstream ones.
+func Buffer[T any](p *Pipeline, in <-chan T, size int) <-chan T {
+	out := make(chan T, size)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			if err := send(ctx, out, elem); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
+
+// Window groups the elements of the input channel, sending the
+// groups to the returned channel. A window is sent once it reaches
+// the provided size, or once the provided duration, counted from
+// its first element, is exceeded. A zero value disables each limit,
+// but at least one of them must be provided.
+//
+// When the input channel is closed, the last, possibly incomplete,
+// window is sent.
+func Window[T any](p *Pipeline, in <-chan T, size int, d time.Duration) <-chan []T {
+	out := make(chan []T)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		var window []T
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		flush := func() error {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if len(window) == 0 {
+				return nil
+			}
+			w := window
+			window = nil
+			return send(ctx, out, w)
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timeout:
+				if err := flush(); err != nil {
+					return err
+				}
+			case elem, ok := <-in:
+				if !ok {
+					return flush()
+				}
+				window = append(window, elem)
+				if d > 0 && timer == nil {
+					timer = time.NewTimer(d)
+					timeout = timer.C
+				}
+				if size > 0 && len(window) >= size {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	})
+	return out
+}
+
+// ForEach calls the provided function for each element of the
+// input channel. Its usually the last stage of a Pipeline. An
+// error returned by the function cancels the Pipeline.
+func ForEach[T any](p *Pipeline, in <-chan T, fn func(ctx context.Context, elem T) error) {
+	p.Go(func(ctx context.Context) error {
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			if err := fn(ctx, elem); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// FromSubscription returns a channel with the elements consumed
+// from the provided subscription, so it can be used as the source
+// of a Pipeline. The channel is closed once the subscription is
+// cancelled and all the remaining elements were consumed.
+//
+// Cancelling the subscription is still responsibility of the caller.
+func FromSubscription[T any](p *Pipeline, sub *Subscription[T]) <-chan T {
+	out := make(chan T)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			slot, err := sub.ConsumeContext(ctx)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := send(ctx, out, slot.Elem); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
+
+// ToFanout publishes all the elements of the input channel in
+// the provided Fanout, so it can be used as the sink of a Pipeline.
+// If the Fanout is closed, the Pipeline is cancelled with ErrClosed.
+func ToFanout[T any](p *Pipeline, in <-chan T, fo *Fanout[T]) {
+	p.Go(func(ctx context.Context) error {
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			if _, err := fo.Publish(elem); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func closeAll[T any](chs []chan T) {
+	for i := 0; i < len(chs); i++ {
+		close(chs[i])
+	}
+}
+
+func receiveOnly[T any](chs []chan T) []<-chan T {
+	result := make([]<-chan T, len(chs))
+	for i := 0; i < len(chs); i++ {
+		result[i] = chs[i]
+	}
+	return result
+}
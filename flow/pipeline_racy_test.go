@@ -0,0 +1,88 @@
+//go:build racy
+
+package flow_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+// This is a racy test. See TestFanout_SupportsRace for more details.
+func TestPipeline_SupportsRace(t *testing.T) {
+	ctx, testCancel := context.WithCancel(context.Background())
+
+	src := flow.NewFanout[int](20)
+	dst := flow.NewFanout[string](20)
+
+	var wg sync.WaitGroup
+
+	// Publish code path.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				src.Publish(i)
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+
+	// Sink consumers code path.
+	subscriptionsVector(ctx, &wg, dst)
+
+	// Short lived pipelines, with all the operators, being
+	// cancelled while elements are in flight.
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				runPipeline(ctx, src, dst)
+			}
+		}()
+	}
+
+	time.AfterFunc(5*time.Second, testCancel)
+	wg.Wait()
+}
+
+func runPipeline(ctx context.Context, src *flow.Fanout[int], dst *flow.Fanout[string]) {
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	sub := src.NewSubscription("")
+	defer sub.Cancel()
+
+	p := flow.NewPipeline(ctx)
+	in := flow.Buffer(p, flow.FromSubscription(p, sub), 10)
+	in = flow.Dedup(p, in, strconv.Itoa, time.Millisecond, 100)
+	parts := flow.Partition(p, in, 2, func(elem int) string {
+		return strconv.Itoa(elem % 3)
+	})
+	tees := flow.Tee(p, flow.Merge(p, parts...), 2)
+	even := flow.Filter(p, tees[0], func(elem int) bool {
+		return elem%2 == 0
+	})
+	strs := flow.Map(p, even, func(ctx context.Context, elem int) (string, error) {
+		return strconv.Itoa(elem), nil
+	})
+	flow.ToFanout(p, strs, dst)
+	windows := flow.Window(p, tees[1], 5, time.Millisecond)
+	flat := flow.FlatMap(p, windows, func(ctx context.Context, window []int) ([]int, error) {
+		return window, nil
+	})
+	limited := flow.RateLimit(p, flat, 100*time.Microsecond, 5)
+	throttled := flow.ThrottleLast(p, flow.ThrottleFirst(p, limited, 50*time.Microsecond), 200*time.Microsecond)
+	flow.ForEach(p, flow.Debounce(p, throttled, 100*time.Microsecond), func(ctx context.Context, elem int) error {
+		return nil
+	})
+	_ = p.Wait()
+}
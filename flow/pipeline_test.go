@@ -0,0 +1,261 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestPipeline_Chain(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+
+	src := source(p, 1, 2, 3, 4, 5)
+	even := flow.Filter(p, src, func(elem int) bool {
+		return elem%2 == 0
+	})
+	doubled := flow.Map(p, even, func(ctx context.Context, elem int) (int, error) {
+		return elem * 2, nil
+	})
+	strs := flow.FlatMap(p, doubled, func(ctx context.Context, elem int) ([]string, error) {
+		return []string{strconv.Itoa(elem), "-"}, nil
+	})
+
+	assert.Equal(t, []string{"4", "-", "8", "-"}, collect(strs))
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_ErrorCancelsAllStages(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+	errBoom := errors.New("boom")
+
+	infinite := make(chan int)
+	p.Go(func(ctx context.Context) error {
+		defer close(infinite)
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case infinite <- i:
+			}
+		}
+	})
+	mapped := flow.Map(p, infinite, func(ctx context.Context, elem int) (int, error) {
+		if elem == 3 {
+			return 0, errBoom
+		}
+		return elem, nil
+	})
+
+	assert.Equal(t, []int{0, 1, 2}, collect(mapped))
+	assert.Equal(t, errBoom, p.Wait())
+}
+
+func TestPipeline_ParentContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := flow.NewPipeline(ctx)
+
+	// Nobody closes the input channel.
+	never := make(chan int)
+	out := flow.Map(p, never, func(ctx context.Context, elem int) (int, error) {
+		return elem, nil
+	})
+	cancel()
+
+	assert.Empty(t, collect(out))
+	assert.Equal(t, context.Canceled, p.Wait())
+}
+
+func TestPipeline_Merge(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+
+	merged := flow.Merge(p, source(p, 1, 2), source(p, 3), source(p, 4, 5))
+
+	result := collect(merged)
+	sort.Ints(result)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, result)
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_Partition(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+
+	outs := flow.Partition(p, source(p, "a1", "b1", "a2", "b2", "a3"), 2, func(elem string) string {
+		return elem[:1]
+	})
+	results := collectAll(outs...)
+
+	// Partitions are hash based, so we only know
+	// that same key elements go together, in order.
+	var sizes []int
+	for _, r := range results {
+		sizes = append(sizes, len(r))
+		for i := 1; i < len(r); i++ {
+			assert.Equal(t, r[0][:1], r[i][:1])
+			assert.Less(t, r[i-1], r[i])
+		}
+	}
+	sort.Ints(sizes)
+	assert.Equal(t, []int{2, 3}, sizes)
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_Tee(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+
+	outs := flow.Tee(p, source(p, 1, 2, 3), 2)
+
+	assert.Equal(t, [][]int{{1, 2, 3}, {1, 2, 3}}, collectAll(outs...))
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_InvalidOutputs(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+	assert.Nil(t, flow.Partition(p, source(p, "a"), 0, func(elem string) string {
+		return elem
+	}))
+	assert.Equal(t, flow.ErrInvalidOutputs, p.Wait())
+
+	p = flow.NewPipeline(context.Background())
+	assert.Nil(t, flow.Tee(p, source(p, 1), -1))
+	assert.Equal(t, flow.ErrInvalidOutputs, p.Wait())
+}
+
+func TestPipeline_Buffer(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+
+	buffered := flow.Buffer(p, source(p, 1, 2, 3), 3)
+
+	// The source is not blocked by a slow consumer.
+	assert.Eventually(t, func() bool {
+		return len(buffered) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []int{1, 2, 3}, collect(buffered))
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_WindowByCount(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+
+	windows := flow.Window(p, source(p, 1, 2, 3, 4, 5), 2, 0)
+
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, collect(windows))
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_WindowByTime(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+
+	in := make(chan int)
+	windows := flow.Window(p, in, 0, 50*time.Millisecond)
+	go func() {
+		defer close(in)
+		in <- 1
+		in <- 2
+		time.Sleep(100 * time.Millisecond)
+		in <- 3
+	}()
+
+	assert.Equal(t, [][]int{{1, 2}, {3}}, collect(windows))
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_FanoutSourceAndSink(t *testing.T) {
+	src := flow.NewFanout[int](10)
+	dst := flow.NewFanout[string](10)
+	sub := src.NewSubscription("")
+	consume, cancel := dst.Subscribe()
+	defer cancel()
+
+	p := flow.NewPipeline(context.Background())
+	strs := flow.Map(p, flow.FromSubscription(p, sub), func(ctx context.Context, elem int) (string, error) {
+		return strconv.Itoa(elem), nil
+	})
+	flow.ToFanout(p, strs, dst)
+
+	src.Publish(1)
+	src.Publish(2)
+	assertConsumed(t, consume, "1", "2")
+
+	// Cancelling the source subscription finishes the pipeline.
+	mustNoErr(sub.Cancel())
+	assert.NoError(t, p.Wait())
+}
+
+func TestPipeline_ClosedFanoutSink(t *testing.T) {
+	dst := flow.NewFanout[int](10)
+	mustNoErr(dst.Close())
+
+	p := flow.NewPipeline(context.Background())
+	flow.ToFanout(p, source(p, 1, 2, 3), dst)
+
+	assert.Equal(t, flow.ErrClosed, p.Wait())
+}
+
+func TestPipeline_ForEach(t *testing.T) {
+	p := flow.NewPipeline(context.Background())
+	errStop := errors.New("stop")
+
+	var seen []int
+	flow.ForEach(p, source(p, 1, 2, 3), func(ctx context.Context, elem int) error {
+		if elem == 3 {
+			return errStop
+		}
+		seen = append(seen, elem)
+		return nil
+	})
+
+	assert.Equal(t, errStop, p.Wait())
+	assert.Equal(t, []int{1, 2}, seen)
+}
+
+// source creates a stage which emits the provided elements.
+func source[T any](p *flow.Pipeline, elems ...T) <-chan T {
+	out := make(chan T)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for _, elem := range elems {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case out <- elem:
+			}
+		}
+		return nil
+	})
+	return out
+}
+
+func collect[T any](in <-chan T) []T {
+	var result []T
+	for elem := range in {
+		result = append(result, elem)
+	}
+	return result
+}
+
+// collectAll concurrently collects all the provided channels,
+// as some operators need all of them to be consumed.
+func collectAll[T any](ins ...<-chan T) [][]T {
+	results := make([][]T, len(ins))
+	done := make(chan struct{})
+	for i := range ins {
+		i := i
+		go func() {
+			results[i] = collect(ins[i])
+			done <- struct{}{}
+		}()
+	}
+	for range ins {
+		<-done
+	}
+	return results
+}
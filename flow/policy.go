@@ -0,0 +1,68 @@
+package flow
+
+import (
+	"time"
+)
+
+type policyKind int
+
+const (
+	dropOldest policyKind = iota
+	dropNewest
+	block
+	blockTimeout
+)
+
+// Policy determines what happens when an element is published
+// and a subscriber buffer is already full. See the constructors
+// below for all the available policies.
+//
+// The zero value of Policy is the DropOldest one.
+type Policy struct {
+	kind    policyKind
+	timeout time.Duration
+}
+
+// DropOldest will discard the oldest element in the subscriber
+// buffer in favour of the new arrived one. This is the default
+// policy.
+func DropOldest() Policy {
+	return Policy{kind: dropOldest}
+}
+
+// DropNewest will discard the new arrived element, preserving
+// the ones that are already in the subscriber buffer.
+func DropNewest() Policy {
+	return Policy{kind: dropNewest}
+}
+
+// Block will make the publisher wait until the subscriber has
+// free space in its buffer or until the subscription is cancelled.
+// No data will be lost, unless the Fanout is closed in the meantime.
+// In such case, the element is discarded for the stalled subscriber,
+// counting as dropped.
+//
+// Beware that publishers wait while holding the Fanout lock. Until
+// the stalled subscriber consumes or is cancelled, it will stall:
+//   - All the publish operations and, as a consequence, the rest of
+//     subscribers.
+//   - Reset, Snapshot and Restore.
+//   - NewSubscription and the other subscribe operations, when using
+//     a group, a replay or restored elements. Plain subscriptions
+//     are not affected.
+//   - The cancellation of group members, including the ones done
+//     with Unsubscribe and UnsubscribeUUID.
+//
+// Consume operations, Status, ExtendedStatus and Subscribers do not
+// wait. Close unblocks the waiting publishers. BlockTimeout bounds
+// the wait.
+func Block() Policy {
+	return Policy{kind: block}
+}
+
+// BlockTimeout is same as Block, but the publisher will only wait
+// for the provided timeout. After that, the new arrived element will
+// be discarded for the stalled subscriber.
+func BlockTimeout(timeout time.Duration) Policy {
+	return Policy{kind: blockTimeout, timeout: timeout}
+}
@@ -0,0 +1,106 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestFanout_Publish_DropOldestIsDefault(t *testing.T) {
+	fo := flow.NewFanout[int](2)
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	assert.Equal(t, 0, mustPublish(fo, 1))
+	assert.Equal(t, 0, mustPublish(fo, 2))
+	assert.Equal(t, 1, mustPublish(fo, 3), "one subscriber should have dropped an element")
+
+	assertConsumed(t, consume, 2, 3)
+}
+
+func TestFanout_Publish_DropNewest(t *testing.T) {
+	fo := flow.NewFanout[int](2, flow.WithFanoutPolicy[int](flow.DropNewest()))
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	fo.Publish(1)
+	fo.Publish(2)
+	assert.Equal(t, 1, mustPublish(fo, 3))
+
+	assertConsumed(t, consume, 1, 2)
+}
+
+func TestFanout_Publish_SubscriberPolicyOverridesFanout(t *testing.T) {
+	fo := flow.NewFanout[int](1, flow.WithFanoutPolicy[int](flow.DropNewest()))
+	consumeOldest, cancel1 := fo.Subscribe(flow.WithSubscriberPolicy[int](flow.DropOldest()))
+	defer cancel1()
+	consumeNewest, cancel2 := fo.Subscribe()
+	defer cancel2()
+
+	fo.Publish(1)
+	assert.Equal(t, 2, mustPublish(fo, 2))
+
+	assertConsumed(t, consumeOldest, 2)
+	assertConsumed(t, consumeNewest, 1)
+}
+
+func TestFanout_Publish_Block(t *testing.T) {
+	fo := flow.NewFanout[int](1, flow.WithFanoutPolicy[int](flow.Block()))
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	fo.Publish(1)
+
+	published := make(chan int)
+	go func() {
+		published <- mustPublish(fo, 2)
+	}()
+	select {
+	case <-published:
+		t.Fatal("publisher should be blocked until consumer frees space")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assertConsumed(t, consume, 1)
+	assert.Equal(t, 0, <-published)
+	assertConsumed(t, consume, 2)
+}
+
+func TestFanout_Publish_Block_UnblockedByCancel(t *testing.T) {
+	fo := flow.NewFanout[int](1, flow.WithFanoutPolicy[int](flow.Block()))
+	_, cancel := fo.Subscribe()
+
+	fo.Publish(1)
+
+	ctx, done := context.WithCancel(context.Background())
+	go func() {
+		fo.Publish(2)
+		done()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mustNoErr(cancel())
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("publisher should be unblocked after subscriber cancellation")
+	}
+}
+
+func TestFanout_Publish_BlockTimeout(t *testing.T) {
+	fo := flow.NewFanout[int](1, flow.WithFanoutPolicy[int](flow.BlockTimeout(50*time.Millisecond)))
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	fo.Publish(1)
+
+	start := time.Now()
+	assert.Equal(t, 1, mustPublish(fo, 2))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(50*time.Millisecond))
+
+	assertConsumed(t, consume, 1)
+}
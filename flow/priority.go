@@ -0,0 +1,130 @@
+package flow
+
+// WithFanoutPriorities enables the provided number of priority
+// levels, from 0, the default one, to levels-1, the highest one.
+// See PublishPriority.
+//
+// Consumers always receive the queued elements with the highest
+// priority first, in publish order among the same priority. When
+// a subscriber buffer is full, the DropOldest and DropNewest policies
+// discard the element with the lowest priority, the oldest or the
+// newest one among them, respectively.
+func WithFanoutPriorities[T any](levels int) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.priorities = levels
+	}
+}
+
+// PublishPriority is same as Publish, but with the provided priority.
+// Out of range priorities are adjusted to the closest valid one. If
+// priorities are not enabled, its same as Publish. See
+// WithFanoutPriorities.
+func (fo *Fanout[T]) PublishPriority(elem T, priority int) (int, error) {
+	return fo.publishSlot(&Slot[T]{
+		TimeStamp: fo.now(),
+		Priority:  fo.priority(priority),
+		Elem:      elem,
+	})
+}
+
+// priority adjusts the provided priority to the closest
+// valid one. Zero if priorities are not enabled.
+func (fo *Fanout[T]) priority(priority int) int {
+	if priority >= fo.priorities {
+		priority = fo.priorities - 1
+	}
+	if priority < 0 {
+		priority = 0
+	}
+	return priority
+}
+
+// newLanes returns the queues of the subscriber slots, one per
+// priority level. Nil if there is only one level, as in such case,
+// the subscriber channel order is enough.
+//
+// Lanes index the slots queued in the subscriber channel, whose
+// elements act as tickets: each slot taken from the channel is
+// exchanged by the next one in the lanes. See subscriber.dequeued.
+func newLanes[T any](levels int) [][]*Slot[T] {
+	if levels < 2 {
+		return nil
+	}
+	return make([][]*Slot[T], levels)
+}
+
+// enlane queues the slot in the lane of its priority.
+// Callers must hold the subscriber lock.
+func (s *subscriber[T]) enlane(sl *Slot[T]) {
+	s.lanes[sl.Priority] = append(s.lanes[sl.Priority], sl)
+}
+
+// unlane removes the slot from the lanes, if present.
+// Callers must hold the subscriber lock.
+func (s *subscriber[T]) unlane(sl *Slot[T]) {
+	lane := s.lanes[sl.Priority]
+	for i := 0; i < len(lane); i++ {
+		if lane[i] == sl {
+			s.lanes[sl.Priority] = append(lane[:i], lane[i+1:]...)
+			return
+		}
+	}
+}
+
+// next removes and returns the oldest slot with the highest
+// priority. Callers must hold the subscriber lock.
+func (s *subscriber[T]) next() *Slot[T] {
+	for p := len(s.lanes) - 1; p >= 0; p-- {
+		if lane := s.lanes[p]; len(lane) > 0 {
+			sl := lane[0]
+			lane[0] = nil
+			s.lanes[p] = lane[1:]
+			return sl
+		}
+	}
+	return nil
+}
+
+// evict makes room for the provided slot, which is already in the
+// lanes, discarding the oldest, or the newest, slot with the lowest
+// priority. It could be the provided slot itself. The discarded slot
+// is returned. Callers must hold the subscriber lock.
+func (s *subscriber[T]) evict(sl *Slot[T], newest bool) *Slot[T] {
+	select {
+	case ticket := <-s.ch:
+		victim := s.lowest(newest)
+		if victim == sl {
+			// There is space, as only consumers can take
+			// elements in the meantime.
+			s.ch <- ticket
+		} else {
+			s.ch <- sl
+		}
+		return victim
+	default:
+		// Consumers emptied the channel in the
+		// meantime, or its an unbuffered one.
+		select {
+		case s.ch <- sl:
+			return nil
+		default:
+			return sl
+		}
+	}
+}
+
+// lowest returns the oldest, or the newest, slot with the
+// lowest priority. Callers must hold the subscriber lock.
+func (s *subscriber[T]) lowest(newest bool) *Slot[T] {
+	for p := 0; p < len(s.lanes); p++ {
+		lane := s.lanes[p]
+		if len(lane) == 0 {
+			continue
+		}
+		if newest {
+			return lane[len(lane)-1]
+		}
+		return lane[0]
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func mustPublishPriority[T any](fo *flow.Fanout[T], elem T, priority int) int {
+	drops, err := fo.PublishPriority(elem, priority)
+	mustNoErr(err)
+	return drops
+}
+
+func TestFanout_Priorities(t *testing.T) {
+	fo := flow.NewFanout[string](10, flow.WithFanoutPriorities[string](3))
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublishPriority(fo, "a", 0)
+	mustPublish(fo, "b")
+	mustPublishPriority(fo, "x", 2)
+	mustPublishPriority(fo, "m", 1)
+	mustPublishPriority(fo, "y", 2)
+
+	slot, err := consume()
+	require.NoError(t, err)
+	assert.Equal(t, "x", slot.Elem)
+	assert.Equal(t, 2, slot.Priority)
+	assert.Equal(t, uint64(3), slot.ID)
+
+	assertConsumed(t, consume, "y", "m", "a", "b")
+	assert.Equal(t, 0, fo.Status()[""])
+}
+
+func TestFanout_Priorities_OutOfRange(t *testing.T) {
+	fo := flow.NewFanout[string](10, flow.WithFanoutPriorities[string](2))
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublishPriority(fo, "a", -1)
+	mustPublishPriority(fo, "b", 5)
+
+	slot, err := consume()
+	require.NoError(t, err)
+	assert.Equal(t, "b", slot.Elem)
+	assert.Equal(t, 1, slot.Priority)
+	slot, err = consume()
+	require.NoError(t, err)
+	assert.Equal(t, "a", slot.Elem)
+	assert.Equal(t, 0, slot.Priority)
+}
+
+func TestFanout_Priorities_Disabled(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublishPriority(fo, "a", 0)
+	mustPublishPriority(fo, "b", 2)
+
+	slot, err := consume()
+	require.NoError(t, err)
+	assert.Equal(t, "a", slot.Elem)
+	assert.Equal(t, 0, slot.Priority)
+	assertConsumed(t, consume, "b")
+}
+
+func TestFanout_Priorities_DropOldest(t *testing.T) {
+	var dropped []string
+	fo := flow.NewFanout[string](3,
+		flow.WithFanoutPriorities[string](3),
+		flow.WithFanoutDropHook(func(d flow.Drop[string]) {
+			dropped = append(dropped, d.Slot.Elem)
+		}),
+	)
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublishPriority(fo, "a", 0)
+	mustPublishPriority(fo, "x", 2)
+	mustPublishPriority(fo, "b", 0)
+	assert.Equal(t, 1, mustPublishPriority(fo, "m", 1)) // Evicts "a".
+	assert.Equal(t, 1, mustPublishPriority(fo, "c", 0)) // Evicts "b", which is older.
+	assert.Equal(t, 1, mustPublishPriority(fo, "y", 2)) // Evicts "c".
+	assert.Equal(t, 1, mustPublishPriority(fo, "n", 1)) // Evicts "m".
+	assert.Equal(t, 1, mustPublishPriority(fo, "z", 2)) // Evicts "n".
+
+	assert.Equal(t, []string{"a", "b", "c", "m", "n"}, dropped)
+	assert.Equal(t, 3, fo.Status()[""])
+	assertConsumed(t, consume, "x", "y", "z")
+	assert.Equal(t, uint64(5), fo.ExtendedStatus().Dropped)
+}
+
+func TestFanout_Priorities_DropNewest(t *testing.T) {
+	fo := flow.NewFanout[string](3,
+		flow.WithFanoutPriorities[string](3),
+		flow.WithFanoutPolicy[string](flow.DropNewest()),
+	)
+	consume, cancel := fo.Subscribe()
+	defer cancel()
+
+	mustPublishPriority(fo, "a", 0)
+	mustPublishPriority(fo, "b", 0)
+	mustPublishPriority(fo, "x", 2)
+	assert.Equal(t, 1, mustPublishPriority(fo, "m", 1)) // Evicts "b".
+	assert.Equal(t, 1, mustPublishPriority(fo, "c", 0)) // Evicts itself.
+
+	assertConsumed(t, consume, "x", "m", "a")
+}
+
+func TestFanout_Priorities_Replay(t *testing.T) {
+	fo := flow.NewFanout[string](10,
+		flow.WithFanoutPriorities[string](2),
+		flow.WithFanoutRetention[string](10, 0),
+	)
+	mustPublishPriority(fo, "a", 0)
+	mustPublishPriority(fo, "x", 1)
+
+	consume, cancel := fo.Subscribe(flow.WithSubscriberReplay[string]())
+	defer cancel()
+	mustPublishPriority(fo, "y", 1)
+
+	assertConsumed(t, consume, "x", "y", "a")
+}
+
+func TestFanout_Priorities_Coalesce(t *testing.T) {
+	fo := flow.NewFanout[event](10, flow.WithFanoutPriorities[event](2))
+	sub := fo.NewSubscription("", flow.WithSubscriberCoalesce(func(e event) string {
+		return e.Entity
+	}))
+	defer sub.Cancel()
+
+	mustPublishPriority(fo, event{"a", 1}, 0)
+	mustPublishPriority(fo, event{"b", 1}, 0)
+	mustPublishPriority(fo, event{"b", 2}, 1) // Replaces the queued one, raising its priority.
+
+	for _, want := range []event{{"b", 2}, {"a", 1}} {
+		slot, err := sub.Consume()
+		require.NoError(t, err)
+		assert.Equal(t, want, slot.Elem)
+	}
+	assert.Equal(t, 0, fo.Status()[""])
+}
+
+func TestFanout_Priorities_Unbuffered(t *testing.T) {
+	fo := flow.NewFanout[string](0, flow.WithFanoutPriorities[string](2))
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	assert.Equal(t, 1, mustPublishPriority(fo, "a", 1), "nobody is waiting")
+
+	consumed := make(chan string)
+	go func() {
+		slot, err := sub.Consume()
+		mustNoErr(err)
+		consumed <- slot.Elem
+	}()
+	assert.Eventually(t, func() bool {
+		return mustPublishPriority(fo, "b", 1) == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "b", <-consumed)
+}
@@ -0,0 +1,246 @@
+package flow
+
+import (
+	"context"
+	"time"
+
+	"go.eloylp.dev/kit/moment"
+)
+
+// tokenBucket allows bursts of up to burst elements, refilling
+// one token per every duration. Its not thread safe. Callers
+// must serialize the access.
+type tokenBucket struct {
+	every      time.Duration
+	burst      int
+	tokens     int
+	refilledAt time.Time
+}
+
+func newTokenBucket(every time.Duration, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{every: every, burst: burst, tokens: burst}
+}
+
+// reserve takes a token if available, returning zero. If not,
+// it returns how much time to wait for the next one.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b.every <= 0 {
+		return 0
+	}
+	b.refill(now)
+	if b.tokens == 0 {
+		return b.refilledAt.Add(b.every).Sub(now)
+	}
+	if b.tokens == b.burst {
+		// Refilling only starts once the bucket is not full.
+		b.refilledAt = now
+	}
+	b.tokens--
+	return 0
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.tokens >= b.burst {
+		return
+	}
+	n := int(now.Sub(b.refilledAt) / b.every)
+	if n <= 0 {
+		return
+	}
+	b.tokens += n
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.refilledAt = b.refilledAt.Add(time.Duration(n) * b.every)
+}
+
+// RateOpt represents a configuration option for the
+// rate related operators. See implementations below.
+type RateOpt func(c *rateConfig)
+
+type rateConfig struct {
+	clock moment.Clock
+	drop  bool
+}
+
+func newRateConfig(opts []RateOpt) *rateConfig {
+	c := &rateConfig{clock: moment.NewClock()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithRateClock sets the clock used by the operator.
+// Useful for testing. See moment.FakeClock.
+func WithRateClock(clock moment.Clock) RateOpt {
+	return func(c *rateConfig) {
+		c.clock = clock
+	}
+}
+
+// WithRateDrop makes RateLimit discard the elements exceeding
+// the rate, instead of delaying them.
+func WithRateDrop() RateOpt {
+	return func(c *rateConfig) {
+		c.drop = true
+	}
+}
+
+// RateLimit sends the elements of the input channel to the returned
+// one at a max rate of one element per every duration, allowing bursts
+// of up to burst elements (token bucket). By default, exceeding elements
+// are delayed, slowing down the upstream stages. See WithRateDrop for
+// discarding them instead.
+func RateLimit[T any](p *Pipeline, in <-chan T, every time.Duration, burst int, opts ...RateOpt) <-chan T {
+	out := make(chan T)
+	c := newRateConfig(opts)
+	bucket := newTokenBucket(every, burst)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		for {
+			elem, ok, err := receive(ctx, in)
+			if !ok {
+				return err
+			}
+			wait := bucket.reserve(c.clock.Now())
+			if wait > 0 && c.drop {
+				continue
+			}
+			for wait > 0 {
+				if err := sleep(ctx, c.clock, wait); err != nil {
+					return err
+				}
+				wait = bucket.reserve(c.clock.Now())
+			}
+			if err := send(ctx, out, elem); err != nil {
+				return err
+			}
+		}
+	})
+	return out
+}
+
+// ThrottleFirst sends the first element of the input channel to the
+// returned one, discarding the following ones till the provided
+// duration passes. Then, the next element is sent, and so on.
+func ThrottleFirst[T any](p *Pipeline, in <-chan T, d time.Duration, opts ...RateOpt) <-chan T {
+	return RateLimit(p, in, d, 1, append(opts, WithRateDrop())...)
+}
+
+// ThrottleLast sends, at most, one element per the provided duration.
+// The duration starts counting with the first received element, and
+// once it passes, the last received one is sent, discarding the rest.
+//
+// When the input channel is closed, the pending element, if any, is sent.
+func ThrottleLast[T any](p *Pipeline, in <-chan T, d time.Duration, opts ...RateOpt) <-chan T {
+	c := newRateConfig(opts)
+	return latest(p, in, func(timer moment.Timer) moment.Timer {
+		if timer != nil {
+			return timer
+		}
+		return c.clock.NewTimer(d)
+	})
+}
+
+// Debounce only sends an element of the input channel once the
+// provided duration passes without receiving a new one. Useful
+// for waiting to a burst of elements to settle down.
+//
+// When the input channel is closed, the pending element, if any, is sent.
+func Debounce[T any](p *Pipeline, in <-chan T, d time.Duration, opts ...RateOpt) <-chan T {
+	c := newRateConfig(opts)
+	return latest(p, in, func(timer moment.Timer) moment.Timer {
+		if timer != nil {
+			timer.Stop()
+		}
+		return c.clock.NewTimer(d)
+	})
+}
+
+// latest holds the last received element, sending it once the timer
+// fires. The provided function returns the timer to be used after
+// receiving each element, given the current one, if any.
+func latest[T any](p *Pipeline, in <-chan T, next func(timer moment.Timer) moment.Timer) <-chan T {
+	out := make(chan T)
+	p.Go(func(ctx context.Context) error {
+		defer close(out)
+		var last T
+		var pending bool
+		var timer moment.Timer
+		var timeout <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timeout:
+				timer, timeout, pending = nil, nil, false
+				if err := send(ctx, out, last); err != nil {
+					return err
+				}
+			case elem, ok := <-in:
+				if !ok {
+					if timer != nil {
+						timer.Stop()
+					}
+					if !pending {
+						return nil
+					}
+					return send(ctx, out, last)
+				}
+				last, pending = elem, true
+				timer = next(timer)
+				timeout = timer.C()
+			}
+		}
+	})
+	return out
+}
+
+// sleep waits for the provided duration, according
+// to the clock, or till the context ends.
+func sleep(ctx context.Context, clock moment.Clock, d time.Duration) error {
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// WithSubscriberRateLimit limits the rate at which elements are
+// enqueued for the subscriber to one per every duration, allowing
+// bursts of up to burst elements (token bucket). Exceeding elements
+// are discarded at publish time and counted as throttled. See
+// SubscriberStatus.
+//
+// Elements are never delayed, as that would stall the publisher. For
+// such cases, or for throttle-last and debounce semantics, use the
+// RateLimit, ThrottleLast and Debounce operators with FromSubscription.
+func WithSubscriberRateLimit[T any](every time.Duration, burst int) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.limiter = newTokenBucket(every, burst)
+	}
+}
+
+// WithSubscriberThrottleFirst enqueues the first published element for
+// the subscriber, discarding the following ones till the provided
+// duration passes. Its same as WithSubscriberRateLimit with a burst of 1.
+func WithSubscriberThrottleFirst[T any](d time.Duration) SubscriberOpt[T] {
+	return WithSubscriberRateLimit[T](d, 1)
+}
+
+// throttles tells whether the subscriber rate limit, if any, discards
+// the element. Callers must hold the subscriber lock.
+func (s *subscriber[T]) throttles() bool {
+	if s.limiter == nil || s.limiter.reserve(s.now()) == 0 {
+		return false
+	}
+	s.throttled.Add(1)
+	return true
+}
@@ -0,0 +1,172 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+	"go.eloylp.dev/kit/moment"
+)
+
+const clockDate = "2021-01-01 00:00:00"
+
+// steppingClock is a moment.Clock whose time moves forward
+// the provided step each time Now is called.
+type steppingClock struct {
+	*moment.FakeClock
+	step time.Duration
+}
+
+func (c steppingClock) Now() time.Time {
+	now := c.FakeClock.Now()
+	c.Advance(c.step)
+	return now
+}
+
+func TestRateLimit_Delay(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	p := flow.NewPipeline(context.Background())
+	out := flow.RateLimit(p, source(p, 1, 2, 3, 4), time.Second, 2, flow.WithRateClock(clock))
+
+	assert.Equal(t, 1, <-out)
+	assert.Equal(t, 2, <-out, "burst is allowed")
+
+	clock.BlockUntilCreated(1)
+	clock.Advance(time.Second)
+	assert.Equal(t, 3, <-out)
+
+	clock.BlockUntilCreated(2)
+	clock.Advance(time.Second)
+	assert.Equal(t, 4, <-out)
+
+	_, ok := <-out
+	assert.False(t, ok)
+	require.NoError(t, p.Wait())
+}
+
+func TestRateLimit_Drop(t *testing.T) {
+	clock := steppingClock{moment.NewFakeClock(t, clockDate), 300 * time.Millisecond}
+	p := flow.NewPipeline(context.Background())
+	out := flow.RateLimit(p, source(p, 1, 2, 3, 4, 5, 6, 7, 8), time.Second, 2,
+		flow.WithRateClock(clock),
+		flow.WithRateDrop(),
+	)
+
+	// Elements arrive each 300ms. A token is refilled each second.
+	assert.Equal(t, []int{1, 2, 5, 8}, collect(out))
+	require.NoError(t, p.Wait())
+}
+
+func TestThrottleFirst(t *testing.T) {
+	clock := steppingClock{moment.NewFakeClock(t, clockDate), 400 * time.Millisecond}
+	p := flow.NewPipeline(context.Background())
+	out := flow.ThrottleFirst(p, source(p, 1, 2, 3, 4, 5, 6, 7, 8), time.Second, flow.WithRateClock(clock))
+
+	// Elements arrive each 400ms.
+	assert.Equal(t, []int{1, 4, 7}, collect(out))
+	require.NoError(t, p.Wait())
+}
+
+func TestThrottleLast(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	p := flow.NewPipeline(context.Background())
+	in := make(chan int)
+	out := flow.ThrottleLast(p, in, time.Second, flow.WithRateClock(clock))
+
+	in <- 1
+	in <- 2
+	in <- 3
+	clock.BlockUntilCreated(1)
+	clock.Advance(time.Second)
+	assert.Equal(t, 3, <-out)
+
+	in <- 4
+	clock.BlockUntilCreated(2)
+	close(in)
+	assert.Equal(t, 4, <-out, "pending element is sent on close")
+	_, ok := <-out
+	assert.False(t, ok)
+	require.NoError(t, p.Wait())
+}
+
+func TestDebounce(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	p := flow.NewPipeline(context.Background())
+	in := make(chan int)
+	out := flow.Debounce(p, in, time.Second, flow.WithRateClock(clock))
+
+	in <- 1
+	clock.BlockUntilCreated(1)
+	clock.Advance(500 * time.Millisecond)
+	in <- 2
+	clock.BlockUntilCreated(2)
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, 1, clock.Timers(), "the timer is restarted with each element")
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, 2, <-out)
+
+	in <- 3
+	close(in)
+	assert.Equal(t, 3, <-out, "pending element is sent on close")
+	_, ok := <-out
+	assert.False(t, ok)
+	require.NoError(t, p.Wait())
+}
+
+func TestDebounce_ContextCancelled(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := flow.NewPipeline(ctx)
+	in := make(chan int)
+	out := flow.Debounce(p, in, time.Second, flow.WithRateClock(clock))
+
+	in <- 1
+	cancel()
+	_, ok := <-out
+	assert.False(t, ok)
+	assert.Equal(t, context.Canceled, p.Wait())
+}
+
+func TestFanout_SubscriberRateLimit(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10, flow.WithFanoutNowFunc[int](clock.Now))
+	consume, cancel := fo.SubscribeWith("a", flow.WithSubscriberRateLimit[int](time.Second, 2))
+	defer cancel()
+
+	mustPublish(fo, 1)
+	mustPublish(fo, 2)
+	mustPublish(fo, 3)
+	clock.Advance(time.Second)
+	mustPublish(fo, 4)
+	mustPublish(fo, 5)
+
+	assertConsumed(t, consume, 1, 2, 4)
+	assert.Equal(t, 0, fo.Status()["a"])
+	assert.Equal(t, uint64(2), fo.ExtendedStatus().Subscribers["a"].Throttled)
+}
+
+func TestFanout_SubscriberThrottleFirst(t *testing.T) {
+	clock := moment.NewFakeClock(t, clockDate)
+	fo := flow.NewFanout[int](10, flow.WithFanoutNowFunc[int](clock.Now))
+	consume, cancel := fo.SubscribeWith("a", flow.WithSubscriberThrottleFirst[int](time.Second))
+	defer cancel()
+	unthrottled, cancel2 := fo.Subscribe()
+	defer cancel2()
+
+	mustPublish(fo, 1)
+	mustPublish(fo, 2)
+	clock.Advance(999 * time.Millisecond)
+	mustPublish(fo, 3)
+	clock.Advance(time.Millisecond)
+	mustPublish(fo, 4)
+
+	assertConsumed(t, consume, 1, 4)
+	assertConsumed(t, unthrottled, 1, 2, 3, 4)
+	assert.Equal(t, 0, fo.Status()["a"])
+}
@@ -0,0 +1,199 @@
+package flow
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// compactMinLen is the minimum storage length from which
+// the registry considers compacting its free slots.
+const compactMinLen = 64
+
+// table is a snapshot of the subscribers storage. Its slots
+// can be read concurrently without any lock. Free slots
+// are represented by nil.
+type table[T any] struct {
+	slots []atomic.Pointer[subscriber[T]]
+	len   atomic.Int64
+}
+
+func newTable[T any](capacity int) *table[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &table[T]{slots: make([]atomic.Pointer[subscriber[T]], capacity)}
+}
+
+// registry is the subscribers storage of the Fanout. Writers are
+// serialized by its lock, but readers, like publishers, only need
+// to load the current table. When the table needs to grow or it is
+// compacted, a new one is built and swapped (copy on write), so
+// readers holding the old one can safely continue their iteration.
+//
+// As a consequence, readers could still see a subscriber that was
+// just removed. See subscriber.close for how this is handled.
+type registry[T any] struct {
+	table  atomic.Pointer[table[T]]
+	free   []int
+	active int
+	closed bool
+	l      sync.Mutex
+}
+
+// load returns the current table, creating it if needed.
+func (r *registry[T]) load() *table[T] {
+	if t := r.table.Load(); t != nil {
+		return t
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+	return r.current()
+}
+
+// current is same as load, but callers must hold the lock.
+func (r *registry[T]) current() *table[T] {
+	t := r.table.Load()
+	if t == nil {
+		t = newTable[T](0)
+		r.table.Store(t)
+	}
+	return t
+}
+
+// each calls fn for each one of the registered subscribers.
+func (r *registry[T]) each(fn func(s *subscriber[T])) {
+	t := r.load()
+	n := int(t.len.Load())
+	for i := 0; i < n; i++ {
+		if s := t.slots[i].Load(); s != nil {
+			fn(s)
+		}
+	}
+}
+
+// add registers the subscriber. It prefers reusing a free slot caused
+// by a previous remove operation, so the storage does not grow too much.
+// This is O(1), except when the storage needs to grow.
+//
+// It returns false if the registry is closed.
+func (r *registry[T]) add(s *subscriber[T]) bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if r.closed {
+		return false
+	}
+	t := r.current()
+	r.active++
+	if n := len(r.free); n > 0 {
+		s.index = r.free[n-1]
+		r.free = r.free[:n-1]
+		t.slots[s.index].Store(s)
+		return true
+	}
+	n := int(t.len.Load())
+	if n == len(t.slots) {
+		// Looks like we are full of subscribers. Time to grow ...
+		grown := newTable[T](2 * n)
+		for i := 0; i < n; i++ {
+			grown.slots[i].Store(t.slots[i].Load())
+		}
+		grown.len.Store(int64(n))
+		r.table.Store(grown)
+		t = grown
+	}
+	s.index = n
+	t.slots[n].Store(s)
+	t.len.Store(int64(n + 1))
+	return true
+}
+
+// remove unregisters the subscriber. It returns false if
+// the subscriber was not registered.
+func (r *registry[T]) remove(s *subscriber[T]) bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	t := r.current()
+	if s.index >= int(t.len.Load()) || t.slots[s.index].Load() != s {
+		return false
+	}
+	t.slots[s.index].Store(nil)
+	r.free = append(r.free, s.index)
+	r.active--
+	r.maybeCompact(t)
+	return true
+}
+
+// maybeCompact rebuilds the storage without free slots, once they
+// are more than a half of it. This keeps the publish iterations
+// proportional to the number of active subscribers.
+func (r *registry[T]) maybeCompact(t *table[T]) {
+	n := int(t.len.Load())
+	if n < compactMinLen || len(r.free) <= n/2 {
+		return
+	}
+	compacted := newTable[T](2 * r.active)
+	var j int
+	for i := 0; i < n; i++ {
+		s := t.slots[i].Load()
+		if s == nil {
+			continue
+		}
+		s.index = j
+		compacted.slots[j].Store(s)
+		j++
+	}
+	compacted.len.Store(int64(j))
+	r.free = nil
+	r.table.Store(compacted)
+}
+
+// reset unregisters all subscribers, returning them.
+func (r *registry[T]) reset() []*subscriber[T] {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	var removed []*subscriber[T]
+	t := r.current()
+	n := int(t.len.Load())
+	for i := 0; i < n; i++ {
+		if s := t.slots[i].Load(); s != nil {
+			removed = append(removed, s)
+		}
+	}
+	r.table.Store(newTable[T](0))
+	r.free = nil
+	r.active = 0
+	return removed
+}
+
+// close prevents further subscribers from being added. The
+// registered ones are kept, but returned so they can be closed.
+func (r *registry[T]) close() []*subscriber[T] {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	r.closed = true
+	var subscribers []*subscriber[T]
+	t := r.current()
+	n := int(t.len.Load())
+	for i := 0; i < n; i++ {
+		if s := t.slots[i].Load(); s != nil {
+			subscribers = append(subscribers, s)
+		}
+	}
+	return subscribers
+}
+
+// activeLen returns the number of registered subscribers.
+func (r *registry[T]) activeLen() int {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return r.active
+}
+
+// len returns the size of the storage, including free slots.
+func (r *registry[T]) len() int {
+	return int(r.load().len.Load())
+}
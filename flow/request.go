@@ -0,0 +1,131 @@
+package flow
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Request represents a message published by a RequestReply
+// requester. Responders receive it as the element of a Slot,
+// and answer by calling Reply.
+type Request[Q, R any] struct {
+	// CorrelationID uniquely identifies the request
+	// among the ones of the same RequestReply.
+	CorrelationID string
+	Body          Q
+
+	rr *RequestReply[Q, R]
+}
+
+// Reply sends the response to the requester. It returns
+// ErrRequestNotFound if the requester is not waiting for
+// replies anymore, because its context ended or it already
+// gathered all the expected ones.
+func (r *Request[Q, R]) Reply(resp R) error {
+	return r.rr.reply(r.CorrelationID, resp)
+}
+
+// call represents a request waiting for replies.
+type call[R any] struct {
+	replies []R
+	max     int
+	done    chan struct{}
+}
+
+// RequestReply provides RPC like messaging on top of a Fanout.
+// Requesters publish a request with a unique correlation ID and
+// wait for one or many replies. Responders subscribe to the requests
+// with NewResponder, replying to them through the received Request.
+//
+// This implements all the needed locking mechanisms,
+// so it can be considered thread safe.
+type RequestReply[Q, R any] struct {
+	fo      *Fanout[*Request[Q, R]]
+	pending map[string]*call[R]
+	nextID  atomic.Uint64
+	l       sync.Mutex
+}
+
+// NewRequestReply creates a RequestReply. The provided arguments
+// are used for creating the underlying requests Fanout. See NewFanout.
+func NewRequestReply[Q, R any](maxBuffLen int, opts ...FanoutOpt[*Request[Q, R]]) *RequestReply[Q, R] {
+	return &RequestReply[Q, R]{
+		fo:      NewFanout(maxBuffLen, opts...),
+		pending: make(map[string]*call[R]),
+	}
+}
+
+// NewResponder subscribes to the requests. Consumed elements are
+// requests that should be answered with Request.Reply. Its same
+// as Fanout.NewSubscription, so the same rules apply.
+func (rr *RequestReply[Q, R]) NewResponder(uuid string, opts ...SubscriberOpt[*Request[Q, R]]) *Subscription[*Request[Q, R]] {
+	return rr.fo.NewSubscription(uuid, opts...)
+}
+
+// Request publishes the request and blocks until the first reply
+// arrives or the provided context ends. In such case, ctx.Err() is
+// returned. If the RequestReply is closed, ErrClosed is returned.
+func (rr *RequestReply[Q, R]) Request(ctx context.Context, body Q) (R, error) {
+	replies, err := rr.ScatterGather(ctx, body, 1)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+	return replies[0], nil
+}
+
+// ScatterGather publishes the request to all the responders and
+// blocks until n replies are gathered or the provided context ends.
+// In the latter case, the replies gathered so far are returned along
+// with ctx.Err().
+//
+// If n is zero or less, replies are gathered until the context
+// ends, returning them without error.
+func (rr *RequestReply[Q, R]) ScatterGather(ctx context.Context, body Q, n int) ([]R, error) {
+	id := strconv.FormatUint(rr.nextID.Add(1), 10)
+	c := &call[R]{max: n, done: make(chan struct{})}
+
+	rr.l.Lock()
+	rr.pending[id] = c
+	rr.l.Unlock()
+
+	req := &Request[Q, R]{CorrelationID: id, Body: body, rr: rr}
+	_, err := rr.fo.Publish(req)
+	if err == nil {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			if n > 0 {
+				err = ctx.Err()
+			}
+		}
+	}
+
+	rr.l.Lock()
+	defer rr.l.Unlock()
+	delete(rr.pending, id)
+	return c.replies, err
+}
+
+func (rr *RequestReply[Q, R]) reply(id string, resp R) error {
+	rr.l.Lock()
+	defer rr.l.Unlock()
+
+	c, ok := rr.pending[id]
+	if !ok || (c.max > 0 && len(c.replies) >= c.max) {
+		return ErrRequestNotFound
+	}
+	c.replies = append(c.replies, resp)
+	if len(c.replies) == c.max {
+		close(c.done)
+	}
+	return nil
+}
+
+// Close closes the underlying requests Fanout. Further
+// requests will fail with ErrClosed. See Fanout.Close.
+func (rr *RequestReply[Q, R]) Close() error {
+	return rr.fo.Close()
+}
@@ -0,0 +1,70 @@
+//go:build racy
+
+package flow_test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+// This is a racy test. See TestFanout_SupportsRace for more details.
+func TestRequestReply_SupportsRace(t *testing.T) {
+	ctx, testCancel := context.WithCancel(context.Background())
+
+	rr := flow.NewRequestReply[int, string](20)
+
+	var wg sync.WaitGroup
+
+	// Responders code path, with responders coming and going.
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				serveRequests(ctx, rr)
+			}
+		}()
+	}
+
+	// Requesters code path, with short deadlines, so
+	// some replies arrive once the requester gave up.
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ctx.Err() == nil; i++ {
+				reqCtx, cancel := context.WithTimeout(ctx, time.Duration(rand.Intn(1000))*time.Microsecond)
+				if i%2 == 0 {
+					_, _ = rr.Request(reqCtx, i)
+				} else {
+					_, _ = rr.ScatterGather(reqCtx, i, rand.Intn(5))
+				}
+				cancel()
+			}
+		}()
+	}
+
+	time.AfterFunc(5*time.Second, testCancel)
+	wg.Wait()
+	_ = rr.Close()
+}
+
+func serveRequests(ctx context.Context, rr *flow.RequestReply[int, string]) {
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	sub := rr.NewResponder("")
+	defer sub.Cancel()
+	for {
+		slot, err := sub.ConsumeContext(ctx)
+		if err != nil {
+			return
+		}
+		_ = slot.Elem.Reply(strconv.Itoa(slot.Elem.Body))
+	}
+}
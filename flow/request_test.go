@@ -0,0 +1,170 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+// responder replies to all the requests with the provided prefix
+// plus the request body, till the responder is cancelled.
+func responder(t *testing.T, rr *flow.RequestReply[int, string], prefix string) (stop func()) {
+	t.Helper()
+	sub := rr.NewResponder("")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			slot, err := sub.Consume()
+			if err != nil {
+				return
+			}
+			_ = slot.Elem.Reply(prefix + strconv.Itoa(slot.Elem.Body))
+		}
+	}()
+	return func() {
+		mustNoErr(sub.Cancel())
+		wg.Wait()
+	}
+}
+
+func TestRequestReply_Request(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+	defer responder(t, rr, "a")()
+
+	resp, err := rr.Request(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a1", resp)
+
+	resp, err = rr.Request(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "a2", resp)
+}
+
+func TestRequestReply_Request_NoResponders(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	resp, err := rr.Request(ctx, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Empty(t, resp)
+}
+
+func TestRequestReply_ScatterGather(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+	defer responder(t, rr, "a")()
+	defer responder(t, rr, "b")()
+	defer responder(t, rr, "c")()
+
+	replies, err := rr.ScatterGather(context.Background(), 1, 3)
+	require.NoError(t, err)
+	sort.Strings(replies)
+	assert.Equal(t, []string{"a1", "b1", "c1"}, replies)
+}
+
+func TestRequestReply_ScatterGather_Partial(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+	defer responder(t, rr, "a")()
+	defer responder(t, rr, "b")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	replies, err := rr.ScatterGather(ctx, 1, 3)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	sort.Strings(replies)
+	assert.Equal(t, []string{"a1", "b1"}, replies)
+}
+
+func TestRequestReply_ScatterGather_UntilContextEnds(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+	defer responder(t, rr, "a")()
+	defer responder(t, rr, "b")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	replies, err := rr.ScatterGather(ctx, 1, 0)
+	assert.NoError(t, err)
+	sort.Strings(replies)
+	assert.Equal(t, []string{"a1", "b1"}, replies)
+}
+
+func TestRequestReply_ReplyNotWaiting(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+	sub := rr.NewResponder("")
+	defer sub.Cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan string)
+	go func() {
+		resp, err := rr.Request(ctx, 1)
+		mustNoErr(err)
+		result <- resp
+	}()
+
+	slot, err := sub.Consume()
+	require.NoError(t, err)
+	assert.NotEmpty(t, slot.Elem.CorrelationID)
+	assert.Equal(t, 1, slot.Elem.Body)
+	assert.NoError(t, slot.Elem.Reply("first"))
+	assert.Equal(t, "first", <-result)
+	assert.Equal(t, flow.ErrRequestNotFound, slot.Elem.Reply("second"), "all expected replies were gathered")
+
+	cancel()
+	go func() {
+		_, err := rr.Request(ctx, 2)
+		assert.Equal(t, context.Canceled, err)
+		result <- ""
+	}()
+	slot, err = sub.Consume()
+	require.NoError(t, err)
+	<-result
+	assert.Equal(t, flow.ErrRequestNotFound, slot.Elem.Reply("late"), "requester context ended")
+}
+
+func TestRequestReply_CorrelationIDs(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+	sub := rr.NewResponder("", flow.WithSubscriberBuffLen[*flow.Request[int, string]](10))
+	defer sub.Cancel()
+
+	results := make(chan string, 2)
+	for i := 1; i <= 2; i++ {
+		i := i
+		go func() {
+			resp, err := rr.Request(context.Background(), i)
+			mustNoErr(err)
+			results <- strconv.Itoa(i) + ":" + resp
+		}()
+	}
+	first, err := sub.Consume()
+	require.NoError(t, err)
+	second, err := sub.Consume()
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Elem.CorrelationID, second.Elem.CorrelationID)
+
+	// Reply in reverse order. Each one must reach its requester.
+	require.NoError(t, second.Elem.Reply(strconv.Itoa(second.Elem.Body)))
+	require.NoError(t, first.Elem.Reply(strconv.Itoa(first.Elem.Body)))
+	got := []string{<-results, <-results}
+	sort.Strings(got)
+	assert.Equal(t, []string{"1:1", "2:2"}, got)
+}
+
+func TestRequestReply_Closed(t *testing.T) {
+	rr := flow.NewRequestReply[int, string](10)
+	require.NoError(t, rr.Close())
+
+	_, err := rr.Request(context.Background(), 1)
+	assert.Equal(t, flow.ErrClosed, err)
+}
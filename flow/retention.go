@@ -0,0 +1,91 @@
+package flow
+
+import (
+	"time"
+)
+
+type retention struct {
+	maxLen int
+	maxAge time.Duration
+}
+
+func (r retention) enabled() bool {
+	return r.maxLen > 0 || r.maxAge > 0
+}
+
+// WithFanoutRetention makes the Fanout keep a history of the
+// recently published elements, so late subscribers can request
+// a replay of them with WithSubscriberReplay.
+//
+// The history keeps the last maxLen elements and/or the elements
+// newer than maxAge. A zero value disables each limit, but at
+// least one of them must be provided for enabling the retention.
+func WithFanoutRetention[T any](maxLen int, maxAge time.Duration) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.retention = retention{maxLen: maxLen, maxAge: maxAge}
+	}
+}
+
+// WithSubscriberReplay makes the subscriber receive the retained
+// history of elements before the live ones. See WithFanoutRetention.
+//
+// Ordering is guaranteed and there will be no duplicates. If the
+// history does not fit in the subscriber buffer, only the most
+// recent elements are replayed. Subscriber filters are also applied.
+func WithSubscriberReplay[T any]() SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.replay = true
+	}
+}
+
+// WithSubscriberReplayAfter is same as WithSubscriberReplay, but only
+// the retained elements with an ID greater than the provided one are
+// replayed. Useful for resuming a stream from the last seen element.
+func WithSubscriberReplayAfter[T any](id uint64) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.replay = true
+		s.replayAfter = id
+	}
+}
+
+// retain adds the slot to the history, if retention
+// is enabled. Callers must hold the Fanout lock.
+func (fo *Fanout[T]) retain(sl *Slot[T]) {
+	if !fo.retention.enabled() {
+		return
+	}
+	fo.history = append(fo.history, sl)
+	fo.expireHistory(sl.TimeStamp)
+}
+
+// expireHistory removes the elements that exceed the retention
+// limits. Callers must hold the Fanout lock.
+func (fo *Fanout[T]) expireHistory(now time.Time) {
+	h := fo.history
+	if fo.retention.maxLen > 0 && len(h) > fo.retention.maxLen {
+		h = h[len(h)-fo.retention.maxLen:]
+	}
+	if fo.retention.maxAge > 0 {
+		var i int
+		for i < len(h) && now.Sub(h[i].TimeStamp) > fo.retention.maxAge {
+			i++
+		}
+		h = h[i:]
+	}
+	fo.history = h
+}
+
+// replay enqueues the retained history in the subscriber
+// channel. Only the elements that fit in the buffer are
+// sent. Callers must hold the Fanout lock, so publishers
+// cannot take the free space in the meantime.
+func (fo *Fanout[T]) replay(s *subscriber[T]) {
+	fo.expireHistory(fo.now())
+	var slots []*Slot[T]
+	for i := 0; i < len(fo.history); i++ {
+		if fo.history[i].ID > s.replayAfter && s.accepts(fo.history[i]) {
+			slots = append(slots, fo.history[i])
+		}
+	}
+	s.enqueue(slots)
+}
@@ -0,0 +1,104 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestFanout_Replay_MaxLen(t *testing.T) {
+	fo := flow.NewFanout[int](10, flow.WithFanoutRetention[int](3, 0))
+
+	for i := 1; i <= 5; i++ {
+		fo.Publish(i)
+	}
+
+	consume, cancel := fo.Subscribe(flow.WithSubscriberReplay[int]())
+	defer cancel()
+
+	fo.Publish(6)
+
+	assertConsumed(t, consume, 3, 4, 5, 6)
+	assert.Equal(t, flow.Status{"": 0}, fo.Status(), "there should be no duplicates")
+}
+
+func TestFanout_Replay_MaxAge(t *testing.T) {
+	fo := flow.NewFanout[int](10, flow.WithFanoutRetention[int](0, 50*time.Millisecond))
+
+	fo.Publish(1)
+	time.Sleep(60 * time.Millisecond)
+	fo.Publish(2)
+
+	consume, cancel := fo.Subscribe(flow.WithSubscriberReplay[int]())
+	defer cancel()
+
+	assertConsumed(t, consume, 2)
+	assert.Equal(t, flow.Status{"": 0}, fo.Status())
+}
+
+func TestFanout_Replay_OnlyWhenRequested(t *testing.T) {
+	fo := flow.NewFanout[int](10, flow.WithFanoutRetention[int](3, 0))
+
+	fo.Publish(1)
+
+	_, cancel := fo.Subscribe()
+	defer cancel()
+
+	assert.Equal(t, flow.Status{"": 0}, fo.Status())
+}
+
+func TestFanout_Replay_FitsBufferAndFilter(t *testing.T) {
+	fo := flow.NewFanout[int](10, flow.WithFanoutRetention[int](10, 0))
+
+	for i := 1; i <= 10; i++ {
+		fo.Publish(i)
+	}
+
+	even := func(elem int) bool { return elem%2 == 0 }
+	consume, cancel := fo.Subscribe(
+		flow.WithSubscriberReplay[int](),
+		flow.WithSubscriberFilter(even),
+		flow.WithSubscriberBuffLen[int](3),
+	)
+	defer cancel()
+
+	assertConsumed(t, consume, 6, 8, 10)
+}
+
+func TestFanout_Replay_ResetClearsHistory(t *testing.T) {
+	fo := flow.NewFanout[int](10, flow.WithFanoutRetention[int](10, 0))
+
+	fo.Publish(1)
+	fo.Reset()
+
+	_, cancel := fo.Subscribe(flow.WithSubscriberReplay[int]())
+	defer cancel()
+
+	assert.Equal(t, flow.Status{"": 0}, fo.Status())
+}
+
+func TestFanout_ReplayAfter(t *testing.T) {
+	fo := flow.NewFanout[int](10, flow.WithFanoutRetention[int](10, 0))
+
+	for i := 1; i <= 5; i++ {
+		fo.Publish(i * 10)
+	}
+
+	sub := fo.NewSubscription("", flow.WithSubscriberReplayAfter[int](3))
+	defer sub.Cancel()
+
+	slot, err := sub.Consume()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), slot.ID)
+	assert.Equal(t, 40, slot.Elem)
+	slot, err = sub.Consume()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), slot.ID)
+	assert.Equal(t, 50, slot.Elem)
+	assert.Equal(t, flow.Status{"": 0}, fo.Status())
+}
@@ -0,0 +1,158 @@
+package flow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	segmentExt = ".log"
+	// recordHeaderLen is the length of the record header:
+	// payload length (4 bytes), CRC32 of the timestamp and
+	// the payload (4 bytes) and timestamp (8 bytes).
+	recordHeaderLen = 16
+	// recordMaxLen is the max length of the record payload. It
+	// prevents huge allocations when reading a corrupted header.
+	recordMaxLen = 64 << 20
+)
+
+// segment represents a file of the append only log. Its
+// named after the offset of the first record it contains.
+type segment struct {
+	base    uint64
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func segmentPath(dir string, base uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// listSegments returns all the segments present in the
+// provided directory, sorted by their base offset.
+func listSegments(dir string) ([]*segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []*segment
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, &segment{
+			base:    base,
+			path:    filepath.Join(dir, e.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].base < segments[j].base
+	})
+	return segments, nil
+}
+
+// encodeRecord returns the on disk representation of a record.
+func encodeRecord(ts time.Time, payload []byte) []byte {
+	data := make([]byte, recordHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint64(data[8:16], uint64(ts.UnixNano()))
+	copy(data[recordHeaderLen:], payload)
+	binary.BigEndian.PutUint32(data[4:8], crc32.ChecksumIEEE(data[8:]))
+	return data
+}
+
+// readRecord reads the next record from the reader. It returns
+// io.EOF if there are no more records, io.ErrUnexpectedEOF if the
+// record is incomplete and ErrCorruptedRecord if the checksum
+// does not match or the length exceeds recordMaxLen.
+func readRecord(r io.Reader) (ts time.Time, payload []byte, err error) {
+	header := make([]byte, recordHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return time.Time{}, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > recordMaxLen {
+		return time.Time{}, nil, ErrCorruptedRecord
+	}
+	data := make([]byte, 8+int(length))
+	copy(data, header[8:16])
+	if _, err := io.ReadFull(r, data[8:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return time.Time{}, nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return time.Time{}, nil, ErrCorruptedRecord
+	}
+	nanos := int64(binary.BigEndian.Uint64(data[0:8]))
+	return time.Unix(0, nanos), data[8:], nil
+}
+
+// skipRecords discards the next n records from the reader.
+func skipRecords(r *bufio.Reader, n uint64) error {
+	header := make([]byte, recordHeaderLen)
+	for i := uint64(0); i < n; i++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		if _, err := r.Discard(int(length)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverSegment counts the valid records of the segment, truncating
+// the file at the first incomplete or corrupted one. This could happen
+// if the process crashed in the middle of a write.
+func recoverSegment(s *segment) (records uint64, err error) {
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var validSize int64
+	for {
+		_, payload, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF || err == ErrCorruptedRecord {
+			if err := f.Truncate(validSize); err != nil {
+				return 0, err
+			}
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		validSize += int64(recordHeaderLen + len(payload))
+		records++
+	}
+	s.size = validSize
+	return records, nil
+}
@@ -0,0 +1,180 @@
+package flow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// WithFanoutCodec sets the Codec used for serializing the
+// elements on Snapshot and Restore. Defaults to JSONCodec.
+func WithFanoutCodec[T any](codec Codec[T]) FanoutOpt[T] {
+	return func(fo *Fanout[T]) {
+		fo.codec = codec
+	}
+}
+
+// Snapshot writes the elements still queued for the subscribers
+// with a UUID, so they can be loaded by another Fanout with Restore.
+// Useful for not losing them on graceful restarts. Subscribers without
+// UUID are ignored.
+//
+// The Fanout must be closed first, so no more elements are published.
+// Otherwise, ErrNotClosed is returned. The written elements are taken
+// from the subscriber buffers, so they are not consumed anymore. Elements
+// queued for several subscribers with the same UUID, like the members
+// of a group, are written only once.
+func (fo *Fanout[T]) Snapshot(w io.Writer) error {
+	fo.l.Lock()
+	defer fo.l.Unlock()
+	if !fo.closed {
+		return ErrNotClosed
+	}
+	pending := make(map[string]map[uint64]*Slot[T])
+	fo.subscribers.each(func(s *subscriber[T]) {
+		if s.uuid == "" {
+			return
+		}
+		if pending[s.uuid] == nil {
+			pending[s.uuid] = make(map[uint64]*Slot[T])
+		}
+		// Channels are closed, so this does not block. Concurrent
+		// consumers could still take some of the elements.
+		for sl := range s.ch {
+			sl = s.dequeued(sl)
+			pending[s.uuid][sl.ID] = sl
+		}
+	})
+	uuids := make([]string, 0, len(pending))
+	for uuid := range pending {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	bw := bufio.NewWriter(w)
+	for _, uuid := range uuids {
+		slots := make([]*Slot[T], 0, len(pending[uuid]))
+		for _, sl := range pending[uuid] {
+			slots = append(slots, sl)
+		}
+		sort.Slice(slots, func(i, j int) bool {
+			return slots[i].ID < slots[j].ID
+		})
+		for _, sl := range slots {
+			data, err := fo.codec.Encode(sl.Elem)
+			if err != nil {
+				return err
+			}
+			payload := encodeSnapshotPayload(uuid, sl, data)
+			if len(payload) > recordMaxLen {
+				return ErrRecordTooLarge
+			}
+			if _, err := bw.Write(encodeRecord(sl.TimeStamp, payload)); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Restore loads the elements written by Snapshot. They are queued
+// for the first subscription with the same UUID, before any live or
+// replayed element. If they do not fit in its buffer, only the most
+// recent ones are kept. Further published elements get an ID greater
+// than the restored ones.
+//
+// Its intended to be called just after NewFanout, before any
+// subscription. It returns ErrCorruptedRecord if the snapshot
+// is not valid.
+func (fo *Fanout[T]) Restore(r io.Reader) error {
+	restored := make(map[string][]*Slot[T])
+	var maxID uint64
+	br := bufio.NewReader(r)
+	for {
+		ts, payload, err := readRecord(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		uuid, sl, data, err := decodeSnapshotPayload[T](payload)
+		if err != nil {
+			return err
+		}
+		if sl.Elem, err = fo.codec.Decode(data); err != nil {
+			return err
+		}
+		sl.TimeStamp = ts
+		sl.Priority = fo.priority(sl.Priority)
+		restored[uuid] = append(restored[uuid], sl)
+		if sl.ID > maxID {
+			maxID = sl.ID
+		}
+	}
+
+	fo.l.Lock()
+	defer fo.l.Unlock()
+	if fo.restored == nil {
+		fo.restored = make(map[string][]*Slot[T])
+	}
+	for uuid, slots := range restored {
+		fo.restored[uuid] = append(fo.restored[uuid], slots...)
+	}
+	fo.restoring.Store(int64(len(fo.restored)))
+	if maxID > fo.published.Load() {
+		fo.published.Store(maxID)
+	}
+	return nil
+}
+
+// takeRestored returns and forgets the restored slots
+// for the provided UUID. Callers must hold the Fanout lock.
+func (fo *Fanout[T]) takeRestored(uuid string) []*Slot[T] {
+	slots := fo.restored[uuid]
+	delete(fo.restored, uuid)
+	fo.restoring.Store(int64(len(fo.restored)))
+	return slots
+}
+
+// restore queues the provided slots in the subscriber buffer.
+// Callers must hold the Fanout lock. See subscriber.enqueue.
+func (s *subscriber[T]) restore(slots []*Slot[T]) {
+	s.enqueue(slots)
+	// Retained elements could also be in the snapshot.
+	if len(slots) > 0 && slots[len(slots)-1].ID > s.replayAfter {
+		s.replayAfter = slots[len(slots)-1].ID
+	}
+}
+
+// encodeSnapshotPayload returns the payload of a snapshot record.
+// The timestamp of the slot goes in the record header.
+func encodeSnapshotPayload[T any](uuid string, sl *Slot[T], data []byte) []byte {
+	payload := make([]byte, 0, 2*binary.MaxVarintLen64+len(uuid)+binary.MaxVarintLen64+len(data))
+	payload = binary.AppendUvarint(payload, uint64(len(uuid)))
+	payload = append(payload, uuid...)
+	payload = binary.AppendUvarint(payload, sl.ID)
+	payload = binary.AppendVarint(payload, int64(sl.Priority))
+	return append(payload, data...)
+}
+
+// decodeSnapshotPayload is the inverse of encodeSnapshotPayload.
+func decodeSnapshotPayload[T any](payload []byte) (uuid string, sl *Slot[T], data []byte, err error) {
+	length, n := binary.Uvarint(payload)
+	if n <= 0 || length > uint64(len(payload)-n) {
+		return "", nil, nil, ErrCorruptedRecord
+	}
+	payload = payload[n:]
+	uuid, payload = string(payload[:length]), payload[length:]
+	id, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return "", nil, nil, ErrCorruptedRecord
+	}
+	payload = payload[n:]
+	priority, n := binary.Varint(payload)
+	if n <= 0 {
+		return "", nil, nil, ErrCorruptedRecord
+	}
+	return uuid, &Slot[T]{ID: id, Priority: int(priority)}, payload[n:], nil
+}
@@ -0,0 +1,112 @@
+//go:build racy
+
+package flow_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+// This is a racy test. See TestFanout_SupportsRace for more details.
+func TestFanout_Snapshot_SupportsRace(t *testing.T) {
+	ctx, testCancel := context.WithCancel(context.Background())
+	time.AfterFunc(5*time.Second, testCancel)
+
+	// Each generation restores the snapshot of the previous
+	// one, while consumers are still taking elements.
+	var snapshot bytes.Buffer
+	for ctx.Err() == nil {
+		fo := flow.NewFanout[int](10, flow.WithFanoutPriorities[int](2))
+		if err := fo.Restore(&snapshot); err != nil {
+			t.Fatal(err)
+		}
+		snapshot.Reset()
+		runSnapshotGeneration(fo, &snapshot)
+	}
+}
+
+func runSnapshotGeneration(fo *flow.Fanout[int], snapshot *bytes.Buffer) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		uuid := strconv.Itoa(i % 3)
+		var opts []flow.SubscriberOpt[int]
+		if i%2 == 0 {
+			opts = append(opts, flow.WithSubscriberGroup[int](flow.RoundRobin))
+		}
+		sub := fo.NewSubscription(uuid, opts...)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := sub.Consume(); err != nil {
+					return
+				}
+				time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			if _, err := fo.PublishPriority(i, i%2); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Microsecond)
+		}
+	}()
+
+	time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
+	_ = fo.Close()
+	_ = fo.Snapshot(snapshot)
+	wg.Wait()
+}
+
+// This is a racy test. See TestFanout_SupportsRace for more details.
+// Restoring a big snapshot takes time, so forced unsubscribes could
+// happen while the subscriber channel is being filled.
+func TestFanout_UnsubscribeRestoring_SupportsRace(t *testing.T) {
+	ctx, testCancel := context.WithCancel(context.Background())
+	time.AfterFunc(5*time.Second, testCancel)
+
+	const buffLen = 100_000
+	src := flow.NewFanout[int](buffLen)
+	src.NewSubscription("restored")
+	for i := 0; i < buffLen; i++ {
+		_, _ = src.Publish(i)
+	}
+	var snapshot bytes.Buffer
+	_ = src.Close()
+	if err := src.Snapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	for ctx.Err() == nil {
+		fo := flow.NewFanout[int](buffLen)
+		if err := fo.Restore(bytes.NewReader(snapshot.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		subscribed := make(chan struct{})
+		go func() {
+			defer close(subscribed)
+			fo.NewSubscription("restored")
+		}()
+		for done := false; !done; {
+			select {
+			case <-subscribed:
+				done = true
+			default:
+				_, _ = fo.UnsubscribeUUID("restored")
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestFanout_SnapshotRestore(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	consumeA, _ := fo.SubscribeWith("a")
+	fo.SubscribeWith("b")
+	fo.Subscribe()
+
+	mustPublish(fo, "x")
+	mustPublish(fo, "y")
+	mustPublish(fo, "z")
+	assertConsumed(t, consumeA, "x")
+
+	var buf bytes.Buffer
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&buf))
+
+	restored := flow.NewFanout[string](10)
+	require.NoError(t, restored.Restore(&buf))
+
+	consumeB, cancelB := restored.SubscribeWith("b")
+	defer cancelB()
+	consumeA, cancelA := restored.SubscribeWith("a")
+	defer cancelA()
+	consumeC, cancelC := restored.SubscribeWith("c")
+	defer cancelC()
+
+	mustPublish(restored, "w")
+
+	slot, err := consumeA()
+	require.NoError(t, err)
+	assert.Equal(t, "y", slot.Elem)
+	assert.Equal(t, uint64(2), slot.ID)
+	assertConsumed(t, consumeA, "z", "w")
+	assertConsumed(t, consumeB, "x", "y", "z", "w")
+
+	slot, err = consumeC()
+	require.NoError(t, err)
+	assert.Equal(t, "w", slot.Elem)
+	assert.Equal(t, uint64(4), slot.ID, "further IDs must follow the restored ones")
+}
+
+func TestFanout_Snapshot_NotClosed(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	assert.Equal(t, flow.ErrNotClosed, fo.Snapshot(&bytes.Buffer{}))
+}
+
+func TestFanout_Snapshot_TakesElements(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	consume, _ := fo.SubscribeWith("a")
+	mustPublish(fo, "x")
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&bytes.Buffer{}))
+
+	_, err := consume()
+	assert.Error(t, err)
+	assert.Equal(t, 0, fo.Status()["a"])
+}
+
+func TestFanout_Snapshot_Groups(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	fo.SubscribeWith("workers", flow.WithSubscriberGroup[string](flow.RoundRobin))
+	fo.SubscribeWith("workers", flow.WithSubscriberGroup[string](flow.RoundRobin))
+	mustPublish(fo, "x")
+	mustPublish(fo, "y")
+	mustPublish(fo, "z")
+
+	var buf bytes.Buffer
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&buf))
+
+	restored := flow.NewFanout[string](10)
+	require.NoError(t, restored.Restore(&buf))
+	consume, cancel := restored.SubscribeWith("workers", flow.WithSubscriberGroup[string](flow.RoundRobin))
+	defer cancel()
+	assertConsumed(t, consume, "x", "y", "z")
+}
+
+func TestFanout_Restore_Priorities(t *testing.T) {
+	fo := flow.NewFanout[string](10, flow.WithFanoutPriorities[string](3))
+	fo.SubscribeWith("a")
+	mustPublishPriority(fo, "x", 0)
+	mustPublishPriority(fo, "y", 2)
+	mustPublishPriority(fo, "z", 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&buf))
+
+	restored := flow.NewFanout[string](10, flow.WithFanoutPriorities[string](2))
+	require.NoError(t, restored.Restore(&buf))
+	consume, cancel := restored.SubscribeWith("a")
+	defer cancel()
+	mustPublishPriority(restored, "w", 1)
+
+	assertConsumed(t, consume, "y", "z", "w", "x")
+}
+
+func TestFanout_Restore_BufferOverflow(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	fo.SubscribeWith("a")
+	mustPublish(fo, "x")
+	mustPublish(fo, "y")
+	mustPublish(fo, "z")
+
+	var buf bytes.Buffer
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&buf))
+
+	restored := flow.NewFanout[string](2)
+	require.NoError(t, restored.Restore(&buf))
+	consume, cancel := restored.SubscribeWith("a")
+	defer cancel()
+	assertConsumed(t, consume, "y", "z")
+}
+
+func TestFanout_Restore_Replay(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	fo.SubscribeWith("a")
+	mustPublish(fo, "x")
+
+	var buf bytes.Buffer
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&buf))
+
+	restored := flow.NewFanout[string](10, flow.WithFanoutRetention[string](10, 0))
+	require.NoError(t, restored.Restore(&buf))
+	mustPublish(restored, "y")
+	consume, cancel := restored.SubscribeWith("a", flow.WithSubscriberReplay[string]())
+	defer cancel()
+	mustPublish(restored, "z")
+
+	assertConsumed(t, consume, "x", "y", "z")
+}
+
+func TestFanout_Restore_Codec(t *testing.T) {
+	fo := flow.NewFanout[event](10, flow.WithFanoutCodec[event](flow.JSONCodec[event]{}))
+	fo.SubscribeWith("a")
+	mustPublish(fo, event{Entity: "1", State: 7})
+
+	var buf bytes.Buffer
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&buf))
+	assert.True(t, strings.Contains(buf.String(), `"State":7`))
+
+	restored := flow.NewFanout[event](10)
+	require.NoError(t, restored.Restore(&buf))
+	consume, cancel := restored.SubscribeWith("a")
+	defer cancel()
+	assertConsumed(t, consume, event{Entity: "1", State: 7})
+}
+
+func TestFanout_Restore_Corrupted(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	fo.SubscribeWith("a")
+	mustPublish(fo, "x")
+
+	var buf bytes.Buffer
+	require.NoError(t, fo.Close())
+	require.NoError(t, fo.Snapshot(&buf))
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	restored := flow.NewFanout[string](10)
+	assert.Equal(t, flow.ErrCorruptedRecord, restored.Restore(bytes.NewReader(data)))
+}
+
+func TestFanout_Restore_CorruptedLength(t *testing.T) {
+	restored := flow.NewFanout[string](10)
+	header := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	assert.Equal(t, flow.ErrCorruptedRecord, restored.Restore(bytes.NewReader(header)))
+}
@@ -0,0 +1,478 @@
+package flow
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// This is the protocol between the SocketServer and the clients
+// created by SubscribeSocket. All messages are frames, prefixed
+// by their length, as a big endian uint32:
+//
+//   - Once connected, the client sends a subscribe frame, which
+//     contains the ID of the last received Slot (uint64), followed
+//     by the subscriber UUID.
+//   - Then, the server sends an element frame per each consumed
+//     Slot. It contains the Slot ID (uint64), its TimeStamp in unix
+//     nanoseconds (int64) and the element, encoded with the Codec.
+
+const (
+	socketHeaderLen          = 4
+	socketSubscribeHeaderLen = 8
+	socketSlotHeaderLen      = 16
+	socketMaxFrameLen        = 16 << 20
+	defaultSocketTimeout     = 10 * time.Second
+	defaultSocketReconnect   = time.Second
+	defaultSocketBuffLen     = 100
+)
+
+var errSocketFrame = errors.New("socket: invalid frame")
+
+func writeSocketFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, socketHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[socketHeaderLen:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readSocketFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, socketHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length > socketMaxFrameLen {
+		return nil, errSocketFrame
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// SocketServer exposes a Fanout to other processes of the same
+// host, through a Unix domain socket. Each connected client gets
+// its own subscriber, which is cancelled once it disconnects. See
+// SubscribeSocket for the client side.
+//
+// Clients read the socket continuously into a local buffer, so slow
+// consumers do not make the server wait. See WithSocketClientBuffLen.
+// The subscriber Policy only applies when the client process cannot
+// keep up with the reads.
+//
+// This implements all the needed locking mechanisms,
+// so it can be considered thread safe.
+type SocketServer[T any] struct {
+	fo      *Fanout[T]
+	codec   Codec[T]
+	timeout time.Duration
+	subOpts []SubscriberOpt[T]
+	ln      net.Listener
+	conns   map[net.Conn]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+	l       sync.Mutex
+}
+
+// SocketServerOpt represents a configuration option for
+// the SocketServer. See implementations below.
+type SocketServerOpt[T any] func(s *SocketServer[T])
+
+// WithSocketServerTimeout sets the max time for writing an element
+// to a client, as well as for receiving its subscribe frame. Clients
+// exceeding it are considered dead, so they are disconnected.
+// Defaults to 10 seconds.
+func WithSocketServerTimeout[T any](d time.Duration) SocketServerOpt[T] {
+	return func(s *SocketServer[T]) {
+		s.timeout = d
+	}
+}
+
+// WithSocketServerSubscriberOpts sets the options for the
+// subscribers created for each one of the clients.
+func WithSocketServerSubscriberOpts[T any](opts ...SubscriberOpt[T]) SocketServerOpt[T] {
+	return func(s *SocketServer[T]) {
+		s.subOpts = append(s.subOpts, opts...)
+	}
+}
+
+// NewSocketServer creates a SocketServer for the provided Fanout.
+// Elements are serialized with the provided Codec, which must match
+// the one of the clients.
+func NewSocketServer[T any](fo *Fanout[T], codec Codec[T], opts ...SocketServerOpt[T]) *SocketServer[T] {
+	s := &SocketServer[T]{
+		fo:      fo,
+		codec:   codec,
+		timeout: defaultSocketTimeout,
+		conns:   make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListenAndServe listens on the Unix socket of the provided path,
+// calling Serve. A stale socket file, left by a previous process,
+// is replaced.
+func (s *SocketServer[T]) ListenAndServe(path string) error {
+	ln, err := net.Listen("unix", path)
+	if err != nil && isStaleSocket(path) {
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+		ln, err = net.Listen("unix", path)
+	}
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// isStaleSocket tells whether the socket file exists,
+// but nobody is listening on it.
+func isStaleSocket(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return true
+	}
+	conn.Close()
+	return false
+}
+
+// Serve accepts clients on the provided listener, till Close is
+// called. In such case, it returns nil. Otherwise, it returns
+// the error that made the listener fail.
+func (s *SocketServer[T]) Serve(ln net.Listener) error {
+	s.l.Lock()
+	if s.closed {
+		s.l.Unlock()
+		ln.Close()
+		return nil
+	}
+	s.ln = ln
+	s.l.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.l.Lock()
+			closed := s.closed
+			s.l.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *SocketServer[T]) track(conn net.Conn) bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *SocketServer[T]) untrack(conn net.Conn) {
+	s.l.Lock()
+	delete(s.conns, conn)
+	s.l.Unlock()
+	conn.Close()
+	s.wg.Done()
+}
+
+// serve streams the elements of the Fanout to the client.
+func (s *SocketServer[T]) serve(conn net.Conn) {
+	defer s.untrack(conn)
+
+	_ = conn.SetReadDeadline(time.Now().Add(s.timeout))
+	frame, err := readSocketFrame(conn)
+	if err != nil || len(frame) < socketSubscribeHeaderLen {
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	lastID := binary.BigEndian.Uint64(frame)
+	uuid := string(frame[socketSubscribeHeaderLen:])
+
+	opts := append([]SubscriberOpt[T]{}, s.subOpts...)
+	if lastID > 0 {
+		opts = append(opts, WithSubscriberReplayAfter[T](lastID))
+	}
+	sub := s.fo.NewSubscription(uuid, opts...)
+	defer sub.Cancel() //nolint:errcheck
+
+	// Clients do not send anything else, but the connection
+	// needs to be read in order to detect disconnections.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	for {
+		slot, err := sub.ConsumeContext(ctx)
+		if err != nil {
+			return
+		}
+		data, err := s.codec.Encode(slot.Elem)
+		if err != nil {
+			continue
+		}
+		payload := make([]byte, socketSlotHeaderLen+len(data))
+		binary.BigEndian.PutUint64(payload[0:8], slot.ID)
+		binary.BigEndian.PutUint64(payload[8:16], uint64(slot.TimeStamp.UnixNano()))
+		copy(payload[socketSlotHeaderLen:], data)
+		_ = conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		if err := writeSocketFrame(conn, payload); err != nil {
+			return
+		}
+	}
+}
+
+// Close stops accepting clients and disconnects the current
+// ones, waiting for their subscribers to be cancelled. The
+// Fanout is not closed. Closing an already closed SocketServer
+// returns ErrClosed.
+func (s *SocketServer[T]) Close() error {
+	s.l.Lock()
+	if s.closed {
+		s.l.Unlock()
+		return ErrClosed
+	}
+	s.closed = true
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.l.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// socketClient is the client side of the SocketServer.
+// See SubscribeSocket.
+type socketClient[T any] struct {
+	path      string
+	codec     Codec[T]
+	uuid      string
+	reconnect time.Duration
+	buffLen   int
+	ch        chan *Slot[T]
+	done      chan struct{}
+	lastID    uint64
+	conn      net.Conn
+	cancelled bool
+	l         sync.Mutex
+}
+
+// SocketClientOpt represents a configuration option for
+// the clients of a SocketServer. See implementations below.
+type SocketClientOpt[T any] func(c *socketClient[T])
+
+// WithSocketClientUUID sets the UUID of the subscriber
+// created in the server. See Fanout.SubscribeWith.
+func WithSocketClientUUID[T any](uuid string) SocketClientOpt[T] {
+	return func(c *socketClient[T]) {
+		c.uuid = uuid
+	}
+}
+
+// WithSocketClientReconnect sets the interval between reconnection
+// attempts, once the connection with the server is lost. Defaults
+// to 1 second. Zero disables the reconnection.
+func WithSocketClientReconnect[T any](d time.Duration) SocketClientOpt[T] {
+	return func(c *socketClient[T]) {
+		c.reconnect = d
+	}
+}
+
+// WithSocketClientBuffLen sets the length of the local buffer, which
+// holds the received elements till they are consumed. Once full, the
+// oldest element is dropped for making room to the new one, same as
+// the DropOldest Policy. Defaults to 100. Values lower than 1 are
+// considered as 1.
+func WithSocketClientBuffLen[T any](buffLen int) SocketClientOpt[T] {
+	return func(c *socketClient[T]) {
+		c.buffLen = buffLen
+	}
+}
+
+// SubscribeSocket connects to the SocketServer listening on the
+// provided path, returning a ConsumerFunc and a CancelFunc with
+// the same semantics as Fanout.Subscribe. Elements are decoded
+// with the provided Codec. Elements that fail to decode are skipped.
+//
+// If the connection is lost, the client reconnects and subscribes
+// again, sending the ID of the last received Slot. So, if the Fanout
+// of the server has retention enabled, the missed elements are
+// replayed. See WithFanoutRetention and WithSocketClientReconnect.
+// If reconnection is disabled, consumers receive io.EOF once the
+// connection is lost.
+//
+// An error is returned if the first connection attempt fails.
+func SubscribeSocket[T any](path string, codec Codec[T], opts ...SocketClientOpt[T]) (ConsumerFunc[T], CancelFunc, error) { //nolint:gocritic
+	c := &socketClient[T]{
+		path:      path,
+		codec:     codec,
+		reconnect: defaultSocketReconnect,
+		buffLen:   defaultSocketBuffLen,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.buffLen < 1 {
+		c.buffLen = 1
+	}
+	c.ch = make(chan *Slot[T], c.buffLen)
+	if err := c.connect(); err != nil {
+		return nil, nil, err
+	}
+	go c.readLoop()
+	return c.consume, c.cancel, nil
+}
+
+// connect dials the server and sends the subscribe frame.
+func (c *socketClient[T]) connect() error {
+	conn, err := net.Dial("unix", c.path)
+	if err != nil {
+		return err
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.cancelled {
+		conn.Close()
+		return io.EOF
+	}
+	frame := make([]byte, socketSubscribeHeaderLen+len(c.uuid))
+	binary.BigEndian.PutUint64(frame, c.lastID)
+	copy(frame[socketSubscribeHeaderLen:], c.uuid)
+	if err := writeSocketFrame(conn, frame); err != nil {
+		conn.Close()
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// readLoop receives the elements from the server, reconnecting
+// if needed. Its the only owner of the channel of elements.
+func (c *socketClient[T]) readLoop() {
+	defer close(c.ch)
+	for {
+		c.receive()
+		if c.reconnect <= 0 || !c.reconnectLoop() {
+			return
+		}
+	}
+}
+
+// receive reads frames from the current connection, till it fails.
+func (c *socketClient[T]) receive() {
+	conn := c.conn
+	defer conn.Close()
+	for {
+		frame, err := readSocketFrame(conn)
+		if err != nil || len(frame) < socketSlotHeaderLen {
+			return
+		}
+		elem, err := c.codec.Decode(frame[socketSlotHeaderLen:])
+		if err != nil {
+			continue
+		}
+		slot := &Slot[T]{
+			ID:        binary.BigEndian.Uint64(frame[0:8]),
+			TimeStamp: time.Unix(0, int64(binary.BigEndian.Uint64(frame[8:16]))),
+			Elem:      elem,
+		}
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		c.enqueue(slot)
+		c.lastID = slot.ID
+	}
+}
+
+// enqueue queues the slot in the local buffer, dropping the oldest
+// one if its full. So the connection is kept drained, no matter
+// how slow the consumer is.
+func (c *socketClient[T]) enqueue(slot *Slot[T]) {
+	select {
+	case c.ch <- slot:
+		return
+	default:
+	}
+	select {
+	case <-c.ch:
+	default:
+	}
+	// Only consumers can take elements in the meantime, as
+	// this is the only sender. So we can be sure there is space.
+	c.ch <- slot
+}
+
+// reconnectLoop retries the connection till it succeeds,
+// returning true, or the client is cancelled.
+func (c *socketClient[T]) reconnectLoop() bool {
+	for {
+		timer := time.NewTimer(c.reconnect)
+		select {
+		case <-c.done:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		if err := c.connect(); err == nil {
+			return true
+		}
+	}
+}
+
+func (c *socketClient[T]) consume() (*Slot[T], error) {
+	slot, ok := <-c.ch
+	if !ok {
+		return nil, io.EOF
+	}
+	return slot, nil
+}
+
+func (c *socketClient[T]) cancel() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.cancelled {
+		return ErrSubscriberNotFound
+	}
+	c.cancelled = true
+	close(c.done)
+	// The connection could be already closed, if it was lost.
+	_ = c.conn.Close()
+	return nil
+}
@@ -0,0 +1,183 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+// startSocketServer serves the Fanout on the provided socket path.
+func startSocketServer(t *testing.T, fo *flow.Fanout[string], path string, opts ...flow.SocketServerOpt[string]) *flow.SocketServer[string] {
+	t.Helper()
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	srv := flow.NewSocketServer[string](fo, flow.JSONCodec[string]{}, opts...)
+	go srv.Serve(ln) //nolint:errcheck
+	return srv
+}
+
+func socketPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "fanout.sock")
+}
+
+func TestSocket(t *testing.T) {
+	path := socketPath(t)
+	fo := flow.NewFanout[string](10)
+	srv := startSocketServer(t, fo, path)
+	defer srv.Close()
+
+	consume, cancel, err := flow.SubscribeSocket[string](path, flow.JSONCodec[string]{},
+		flow.WithSocketClientUUID[string]("sidecar"),
+	)
+	require.NoError(t, err)
+	waitSubscribers(t, fo, 1)
+	assert.Contains(t, fo.Status(), "sidecar")
+
+	mustPublish(fo, "a")
+	mustPublish(fo, "b")
+
+	slot, err := consume()
+	require.NoError(t, err)
+	assert.Equal(t, "a", slot.Elem)
+	assert.Equal(t, uint64(1), slot.ID)
+	assert.False(t, slot.TimeStamp.IsZero())
+	assertConsumed(t, consume, "b")
+
+	require.NoError(t, cancel())
+	assert.Equal(t, flow.ErrSubscriberNotFound, cancel())
+	_, err = consume()
+	assert.Equal(t, io.EOF, err)
+	waitSubscribers(t, fo, 0)
+}
+
+func TestSocket_ConnectionRefused(t *testing.T) {
+	_, _, err := flow.SubscribeSocket[string](socketPath(t), flow.JSONCodec[string]{})
+	assert.Error(t, err)
+}
+
+func TestSocket_Reconnect(t *testing.T) {
+	path := socketPath(t)
+	fo := flow.NewFanout[string](10, flow.WithFanoutRetention[string](10, 0))
+	srv := startSocketServer(t, fo, path)
+
+	consume, cancel, err := flow.SubscribeSocket[string](path, flow.JSONCodec[string]{},
+		flow.WithSocketClientReconnect[string](10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	defer cancel()
+	waitSubscribers(t, fo, 1)
+
+	mustPublish(fo, "a")
+	assertConsumed(t, consume, "a")
+
+	require.NoError(t, srv.Close())
+	assert.Equal(t, flow.ErrClosed, srv.Close())
+	waitSubscribers(t, fo, 0)
+
+	// Published while the client is disconnected.
+	mustPublish(fo, "b")
+	mustPublish(fo, "c")
+
+	srv = startSocketServer(t, fo, path)
+	defer srv.Close()
+	waitSubscribers(t, fo, 1)
+	mustPublish(fo, "d")
+
+	assertConsumed(t, consume, "b", "c", "d")
+}
+
+func TestSocket_NoReconnect(t *testing.T) {
+	path := socketPath(t)
+	fo := flow.NewFanout[string](10)
+	srv := startSocketServer(t, fo, path)
+
+	consume, cancel, err := flow.SubscribeSocket[string](path, flow.JSONCodec[string]{},
+		flow.WithSocketClientReconnect[string](0),
+	)
+	require.NoError(t, err)
+	defer cancel()
+	waitSubscribers(t, fo, 1)
+
+	require.NoError(t, srv.Close())
+	_, err = consume()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSocket_SlowClient(t *testing.T) {
+	path := socketPath(t)
+	fo := flow.NewFanout[string](10)
+	srv := startSocketServer(t, fo, path, flow.WithSocketServerTimeout[string](200*time.Millisecond))
+	defer srv.Close()
+
+	consume, cancel, err := flow.SubscribeSocket[string](path, flow.JSONCodec[string]{},
+		flow.WithSocketClientBuffLen[string](2),
+	)
+	require.NoError(t, err)
+	defer cancel()
+	waitSubscribers(t, fo, 1)
+
+	// Big elements, so they do not fit in the socket
+	// buffers, as the client is not consuming.
+	var elems []string
+	for i := 0; i < 5; i++ {
+		elems = append(elems, strings.Repeat(string(rune('a'+i)), 256<<10))
+		mustPublish(fo, elems[i])
+	}
+	assert.Eventually(t, func() bool {
+		return fo.Status()[""] == 0
+	}, time.Second, 10*time.Millisecond, "the client keeps the socket drained")
+	time.Sleep(500 * time.Millisecond)
+
+	require.Equal(t, 1, fo.ActiveSubscribers(), "pausing consumers are not disconnected")
+	assert.Equal(t, uint64(0), fo.ExtendedStatus().Dropped)
+	assertConsumed(t, consume, elems[3], elems[4])
+}
+
+func TestSocket_ListenAndServe_StaleSocket(t *testing.T) {
+	path := socketPath(t)
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, ln.Close())
+
+	fo := flow.NewFanout[string](10)
+	srv := flow.NewSocketServer[string](fo, flow.JSONCodec[string]{})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe(path)
+	}()
+
+	var consume flow.ConsumerFunc[string]
+	var cancel flow.CancelFunc
+	require.Eventually(t, func() bool {
+		consume, cancel, err = flow.SubscribeSocket[string](path, flow.JSONCodec[string]{})
+		return err == nil
+	}, time.Second, time.Millisecond)
+	defer cancel()
+	waitSubscribers(t, fo, 1)
+	mustPublish(fo, "a")
+	assertConsumed(t, consume, "a")
+
+	require.NoError(t, srv.Close())
+	assert.NoError(t, <-errs)
+}
+
+func TestSocket_ListenAndServe_InUse(t *testing.T) {
+	path := socketPath(t)
+	fo := flow.NewFanout[string](10)
+	srv := startSocketServer(t, fo, path)
+	defer srv.Close()
+
+	err := flow.NewSocketServer[string](fo, flow.JSONCodec[string]{}).ListenAndServe(path)
+	assert.Error(t, err, "a socket in use should not be replaced")
+}
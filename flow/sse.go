@@ -0,0 +1,104 @@
+package flow
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+type sseHandler[T any] struct {
+	*bridge[T]
+}
+
+// NewSSEHandler returns an http.Handler that streams the elements
+// of the Fanout to clients, using Server-Sent Events. Each client
+// gets its own subscription, which is cancelled once it disconnects.
+//
+// Elements are serialized with the provided Encoder, JSONCodec
+// by default. Elements that fail to encode are skipped. Each event
+// carries the Slot ID, so when clients reconnect sending the
+// Last-Event-ID header, the retained elements they missed are
+// replayed (see WithFanoutRetention).
+//
+// Heartbeats are sent as SSE comments. See WithBridgeHeartbeat.
+func NewSSEHandler[T any](fo *Fanout[T], enc Encoder[T], opts ...BridgeOpt[T]) http.Handler {
+	return &sseHandler[T]{newBridge(fo, enc, opts...)}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *sseHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var opts []SubscriberOpt[T]
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID header", http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, WithSubscriberReplayAfter[T](id))
+	}
+	sub := h.subscribe(opts...)
+	defer sub.Cancel() //nolint:errcheck
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeats, stop := h.heartbeats()
+	defer stop()
+
+	ctx := r.Context()
+	for {
+		var msg []byte
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeats:
+			msg = []byte(": heartbeat\n\n")
+		case slot, ok := <-sub.sub.ch:
+			slot, err := sub.received(slot, ok)
+			if err == errExpired {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			data, err := h.enc.Encode(slot.Elem)
+			if err != nil {
+				continue
+			}
+			msg = h.format(slot.ID, data)
+		}
+		if _, err := w.Write(msg); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// format formats an SSE event. Each line of the data
+// needs its own field, as new lines are delimiters.
+func (h *sseHandler[T]) format(id uint64, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("id: ")
+	buf.WriteString(strconv.FormatUint(id, 10))
+	buf.WriteByte('\n')
+	if h.event != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(h.event)
+		buf.WriteByte('\n')
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
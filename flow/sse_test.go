@@ -0,0 +1,161 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+type stringEncoder struct{}
+
+func (stringEncoder) Encode(elem string) ([]byte, error) {
+	return []byte(elem), nil
+}
+
+func TestSSEHandler(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewSSEHandler[string](fo, stringEncoder{},
+		flow.WithBridgeEvent[string]("update"),
+	))
+	defer srv.Close()
+
+	resp, lines := sseConnect(t, srv.URL, "")
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	waitSubscribers(t, fo, 1)
+
+	fo.Publish("a")
+	fo.Publish("b\nc")
+
+	assert.Equal(t, []string{"id: 1", "event: update", "data: a", ""}, readLines(t, lines, 4))
+	assert.Equal(t, []string{"id: 2", "event: update", "data: b", "data: c", ""}, readLines(t, lines, 5))
+}
+
+func TestSSEHandler_DefaultJSONEncoder(t *testing.T) {
+	fo := flow.NewFanout[[]int](10)
+	srv := httptest.NewServer(flow.NewSSEHandler[[]int](fo, nil))
+	defer srv.Close()
+
+	resp, lines := sseConnect(t, srv.URL, "")
+	defer resp.Body.Close()
+	waitSubscribers(t, fo, 1)
+
+	fo.Publish([]int{1, 2})
+
+	assert.Equal(t, []string{"id: 1", "data: [1,2]", ""}, readLines(t, lines, 3))
+}
+
+func TestSSEHandler_LastEventIDReplay(t *testing.T) {
+	fo := flow.NewFanout[string](10, flow.WithFanoutRetention[string](10, 0))
+	srv := httptest.NewServer(flow.NewSSEHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	fo.Publish("a")
+	fo.Publish("b")
+	fo.Publish("c")
+
+	resp, lines := sseConnect(t, srv.URL, "2")
+	defer resp.Body.Close()
+
+	assert.Equal(t, []string{"id: 3", "data: c", ""}, readLines(t, lines, 3))
+}
+
+func TestSSEHandler_InvalidLastEventID(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewSSEHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "invalid")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 0, fo.ActiveSubscribers())
+}
+
+func TestSSEHandler_Heartbeat(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewSSEHandler[string](fo, stringEncoder{},
+		flow.WithBridgeHeartbeat[string](10*time.Millisecond),
+	))
+	defer srv.Close()
+
+	resp, lines := sseConnect(t, srv.URL, "")
+	defer resp.Body.Close()
+
+	assert.Equal(t, []string{": heartbeat", ""}, readLines(t, lines, 2))
+}
+
+func TestSSEHandler_DisconnectCancelsSubscription(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewSSEHandler[string](fo, stringEncoder{},
+		flow.WithBridgeUUID[string]("browser"),
+	))
+	defer srv.Close()
+
+	resp, _ := sseConnect(t, srv.URL, "")
+	waitSubscribers(t, fo, 1)
+	assert.Equal(t, flow.Status{"browser": 0}, fo.Status())
+
+	resp.Body.Close()
+	waitSubscribers(t, fo, 0)
+}
+
+func TestSSEHandler_ResetEndsStream(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewSSEHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	resp, lines := sseConnect(t, srv.URL, "")
+	defer resp.Body.Close()
+	waitSubscribers(t, fo, 1)
+
+	fo.Reset()
+
+	for lines.Scan() {
+		t.Fatalf("unexpected line %q", lines.Text())
+	}
+}
+
+func sseConnect(t *testing.T, url, lastEventID string) (*http.Response, *bufio.Scanner) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, http.NoBody)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return resp, bufio.NewScanner(resp.Body)
+}
+
+func readLines(t *testing.T, s *bufio.Scanner, n int) []string {
+	t.Helper()
+	lines := make([]string, 0, n)
+	for len(lines) < n && s.Scan() {
+		lines = append(lines, strings.TrimRight(s.Text(), "\r"))
+	}
+	return lines
+}
+
+func waitSubscribers[T any](t *testing.T, fo *flow.Fanout[T], n int) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return fo.ActiveSubscribers() == n
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,260 @@
+package flow
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.eloylp.dev/kit/moment"
+)
+
+type subscriber[T any] struct {
+	ch          chan *Slot[T]
+	handle      uint64
+	uuid        string
+	createdAt   time.Time
+	buffLen     int
+	filter      func(elem T) bool
+	replay      bool
+	replayAfter uint64
+	grouped     bool
+	balance     Balance
+	policy      Policy
+	maxAge      time.Duration
+	now         moment.NowFunc
+	done        chan struct{}
+	doneOnce    sync.Once
+	stop        <-chan struct{}
+	cancelFn    CancelFunc
+	coalesce    func(elem T) string
+	queued      map[string]*Slot[T]
+	limiter     *tokenBucket
+	lanes       [][]*Slot[T]
+	index       int
+	closed      bool
+	rejected    bool
+	l           sync.Mutex
+
+	onConsume func(sl *Slot[T])
+	onExpire  func()
+
+	dropped        atomic.Uint64
+	expired        atomic.Uint64
+	coalescedCount atomic.Uint64
+	throttled      atomic.Uint64
+	lastConsume    atomic.Int64
+}
+
+// SubscriberOpt represents a configuration option for
+// a single subscriber. See implementations below.
+type SubscriberOpt[T any] func(s *subscriber[T])
+
+// WithSubscriberPolicy sets the Policy for the subscriber,
+// overriding the default one of the Fanout.
+func WithSubscriberPolicy[T any](p Policy) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.policy = p
+	}
+}
+
+// WithSubscriberBuffLen sets the buffer length of the subscriber,
+// overriding the maxBuffLen of the Fanout.
+func WithSubscriberBuffLen[T any](buffLen int) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.buffLen = buffLen
+	}
+}
+
+// WithSubscriberFilter sets a predicate that will be evaluated
+// at publish time. Only the elements for which the predicate returns
+// true will be enqueued for the subscriber. Filtered out elements
+// never occupy buffer space.
+//
+// The predicate is executed while holding the Fanout lock, so
+// it should be fast and must not call any Fanout method.
+func WithSubscriberFilter[T any](filter func(elem T) bool) SubscriberOpt[T] {
+	return func(s *subscriber[T]) {
+		s.filter = filter
+	}
+}
+
+// accepts tells whether the provided slot passes
+// the subscriber filter, if any.
+func (s *subscriber[T]) accepts(sl *Slot[T]) bool {
+	return s.filter == nil || s.filter(sl.Elem)
+}
+
+// push will try to enqueue the provided slot in the subscriber
+// channel, following the configured policy. It returns the
+// discarded slot, if any.
+func (s *subscriber[T]) push(sl *Slot[T]) (dropped *Slot[T]) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	// Publishers iterate over a snapshot of the subscribers, so
+	// they could still see a subscriber that was just closed.
+	if s.closed || s.throttles() {
+		return nil
+	}
+	if s.coalesce != nil {
+		var replaced bool
+		if sl, replaced = s.coalesced(sl); replaced {
+			return nil
+		}
+	}
+	if s.lanes != nil {
+		s.enlane(sl)
+	}
+	if s.coalesce != nil || s.lanes != nil {
+		defer func() {
+			if dropped != nil {
+				s.dequeuedLocked(dropped)
+			}
+		}()
+	}
+	select {
+	case s.ch <- sl:
+		return nil
+	default:
+	}
+	switch s.policy.kind {
+	case dropNewest:
+		dropped = sl
+		if s.lanes != nil {
+			dropped = s.evict(sl, true)
+		}
+	case block:
+		select {
+		case s.ch <- sl:
+		case <-s.done:
+		case <-s.stop:
+			dropped = sl
+		}
+	case blockTimeout:
+		timer := time.NewTimer(s.policy.timeout)
+		defer timer.Stop()
+		select {
+		case s.ch <- sl:
+		case <-s.done:
+		case <-s.stop:
+			dropped = sl
+		case <-timer.C:
+			dropped = sl
+		}
+	default:
+		if s.lanes != nil {
+			dropped = s.evict(sl, false)
+			break
+		}
+		select {
+		case dropped = <-s.ch: // remove oldest Slot of subscriber channel
+		default:
+		}
+		// Only consumers can take elements in the meantime, as
+		// publishers are serialized. So we can be sure there is
+		// space, unless its an unbuffered channel.
+		select {
+		case s.ch <- sl:
+		default:
+			dropped = sl
+		}
+	}
+	if dropped != nil {
+		s.dropped.Add(1)
+	}
+	return dropped
+}
+
+// enqueue queues the provided slots, keeping only the most recent
+// ones that fit in the buffer. Callers must hold the Fanout lock,
+// so no publisher takes the free space in the meantime.
+//
+// The subscriber is already registered, so it could be concurrently
+// unsubscribed. Its lock prevents sending to a closed channel.
+func (s *subscriber[T]) enqueue(slots []*Slot[T]) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.closed {
+		return
+	}
+	if free := cap(s.ch) - len(s.ch); len(slots) > free {
+		slots = slots[len(slots)-free:]
+	}
+	for i := 0; i < len(slots); i++ {
+		if s.lanes != nil {
+			s.enlane(slots[i])
+		}
+		s.ch <- slots[i]
+	}
+}
+
+// dequeued must be called with each slot taken from the subscriber
+// channel. It returns the slot that should be actually delivered,
+// which could be a different one, if priorities are enabled. Once
+// this returns, its safe to read the slot without holding the
+// subscriber lock.
+func (s *subscriber[T]) dequeued(sl *Slot[T]) *Slot[T] {
+	if s.coalesce == nil && s.lanes == nil {
+		return sl
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.lanes != nil {
+		if next := s.next(); next != nil {
+			sl = next
+		}
+	}
+	if s.coalesce != nil {
+		s.forget(sl)
+	}
+	return sl
+}
+
+// dequeuedLocked unregisters a slot which is not queued anymore,
+// because it was discarded. Callers must hold the subscriber lock.
+func (s *subscriber[T]) dequeuedLocked(sl *Slot[T]) {
+	if s.lanes != nil {
+		s.unlane(sl)
+	}
+	if s.coalesce != nil {
+		s.forget(sl)
+	}
+}
+
+// consumed registers the moment of the last consume operation.
+func (s *subscriber[T]) consumed(sl *Slot[T]) {
+	s.lastConsume.Store(s.now().UnixNano())
+	if s.onConsume != nil {
+		s.onConsume(sl)
+	}
+}
+
+// lastConsumeTime returns the moment of the last consume
+// operation. If there was no consume operation, the zero
+// value of time.Time is returned.
+func (s *subscriber[T]) lastConsumeTime() time.Time {
+	nanos := s.lastConsume.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// cancel unblocks any publisher waiting on this subscriber.
+func (s *subscriber[T]) cancel() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// close cancels the subscriber and closes its channel, so
+// consumers will receive io.EOF once they consume all the
+// remaining elements. Its safe to call it multiple times.
+func (s *subscriber[T]) close() {
+	s.cancel()
+	s.l.Lock()
+	defer s.l.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
@@ -0,0 +1,206 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Subscription represents a registered subscriber of a Fanout.
+// It offers different ways of consuming elements, so users can
+// choose the one that better fits their use case.
+//
+// Its IMPORTANT to call Cancel once the subscription is not
+// needed anymore. If not, resources could be leaked.
+type Subscription[T any] struct {
+	sub      *subscriber[T]
+	out      chan *Slot[T]
+	pumpOnce sync.Once
+}
+
+// Consume will block until an element arrives. It follows
+// the same semantics as ConsumerFunc.
+func (s *Subscription[T]) Consume() (*Slot[T], error) {
+	for {
+		slot, ok := <-s.sub.ch
+		slot, err := s.received(slot, ok)
+		if err != errExpired {
+			return slot, err
+		}
+	}
+}
+
+// ConsumeContext is same as Consume, but it will stop waiting
+// for elements when the provided context ends. In such case,
+// ctx.Err() will be returned.
+//
+// In case the subscription is cancelled, an io.EOF
+// error will be returned.
+func (s *Subscription[T]) ConsumeContext(ctx context.Context) (*Slot[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case slot, ok := <-s.sub.ch:
+			slot, err := s.received(slot, ok)
+			if err != errExpired {
+				return slot, err
+			}
+		}
+	}
+}
+
+// ConsumeTimeout is same as Consume, but it will stop waiting
+// for elements once the timeout is reached. In such case, an
+// ErrConsumeTimeout error will be returned.
+//
+// In case the subscription is cancelled, an io.EOF
+// error will be returned.
+func (s *Subscription[T]) ConsumeTimeout(timeout time.Duration) (*Slot[T], error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return nil, ErrConsumeTimeout
+		case slot, ok := <-s.sub.ch:
+			slot, err := s.received(slot, ok)
+			if err != errExpired {
+				return slot, err
+			}
+		}
+	}
+}
+
+// ConsumeBatch will block until an element arrives, as ConsumeContext
+// does. Then, it will keep collecting elements until the batch reaches
+// the max size or until the wait duration, counted from the arrival of
+// the first element, is exceeded.
+//
+// If the subscription is cancelled or the context ends while collecting,
+// the partial batch will be returned along with io.EOF or ctx.Err()
+// respectively. So consumers should always process the returned
+// elements before checking the error.
+func (s *Subscription[T]) ConsumeBatch(ctx context.Context, max int, wait time.Duration) ([]*Slot[T], error) {
+	first, err := s.ConsumeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if max < 1 {
+		max = 1
+	}
+	batch := make([]*Slot[T], 1, max)
+	batch[0] = first
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for len(batch) < max {
+		select {
+		case <-ctx.Done():
+			return batch, ctx.Err()
+		case <-timer.C:
+			return batch, nil
+		case slot, ok := <-s.sub.ch:
+			slot, err := s.received(slot, ok)
+			if err == errExpired {
+				continue
+			}
+			if err != nil {
+				return batch, err
+			}
+			batch = append(batch, slot)
+		}
+	}
+	return batch, nil
+}
+
+// Chan returns a receive only channel, which can be used in
+// select statements or for-range loops. All the calls return
+// the same channel.
+//
+// The channel will be closed once the subscription is cancelled
+// and all the remaining elements were received. So consumers should
+// keep receiving until then, as with the ConsumerFunc. If not, the
+// internal goroutine feeding the channel will be leaked.
+func (s *Subscription[T]) Chan() <-chan *Slot[T] {
+	s.pumpOnce.Do(func() {
+		s.out = make(chan *Slot[T])
+		go s.pump()
+	})
+	return s.out
+}
+
+func (s *Subscription[T]) pump() {
+	defer close(s.out)
+	for {
+		slot, err := s.Consume()
+		if err != nil {
+			return
+		}
+		s.out <- slot
+	}
+}
+
+// All returns an iterator over the elements of the subscription.
+// With Go 1.23 or newer, it can be directly used in a for-range
+// statement:
+//
+//	for slot := range sub.All() {
+//		fmt.Println(slot.Elem)
+//	}
+//
+// The iteration ends once the subscription is cancelled and all
+// the remaining elements were consumed, or when the loop is exited.
+// Exiting the loop does not cancel the subscription.
+func (s *Subscription[T]) All() func(yield func(*Slot[T]) bool) {
+	return func(yield func(*Slot[T]) bool) {
+		for {
+			slot, err := s.Consume()
+			if err != nil {
+				return
+			}
+			if !yield(slot) {
+				return
+			}
+		}
+	}
+}
+
+// Handle returns the unique identifier of the subscriber
+// among the ones of the Fanout. See Fanout.Unsubscribe.
+func (s *Subscription[T]) Handle() uint64 {
+	return s.sub.handle
+}
+
+// Cancel terminates the subscription. It follows the same
+// semantics as CancelFunc.
+func (s *Subscription[T]) Cancel() error {
+	return s.sub.cancelFn()
+}
+
+// errExpired is returned by received when the slot
+// exceeds the subscriber max age. Callers should skip
+// it and try to receive the next one.
+var errExpired = errors.New("fanout: expired slot")
+
+// received processes the result of a receive operation over
+// the subscriber channel.
+func (s *Subscription[T]) received(slot *Slot[T], ok bool) (*Slot[T], error) {
+	if !ok {
+		if s.sub.rejected {
+			return nil, ErrClosed
+		}
+		return slot, io.EOF
+	}
+	slot = s.sub.dequeued(slot)
+	if s.sub.isExpired(slot) {
+		return nil, errExpired
+	}
+	s.sub.consumed(slot)
+	return slot, nil
+}
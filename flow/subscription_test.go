@@ -0,0 +1,216 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+func TestSubscription_Consume(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("a")
+	defer sub.Cancel()
+
+	fo.Publish(1)
+
+	slot, err := sub.Consume()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, slot.Elem)
+	assert.Equal(t, flow.Status{"a": 0}, fo.Status())
+}
+
+func TestSubscription_ConsumeContext(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	fo.Publish(1)
+
+	slot, err := sub.ConsumeContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, slot.Elem)
+}
+
+func TestSubscription_ConsumeContext_Cancelled(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	slot, err := sub.ConsumeContext(ctx)
+	assert.Nil(t, slot)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestSubscription_ConsumeContext_EOF(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+
+	mustNoErr(sub.Cancel())
+
+	_, err := sub.ConsumeContext(context.Background())
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSubscription_ConsumeTimeout(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	fo.Publish(1)
+
+	slot, err := sub.ConsumeTimeout(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, slot.Elem)
+
+	slot, err = sub.ConsumeTimeout(50 * time.Millisecond)
+	assert.Nil(t, slot)
+	assert.Equal(t, flow.ErrConsumeTimeout, err)
+}
+
+func TestSubscription_Chan(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	ch, cancel := fo.SubscribeChan()
+
+	fo.Publish(1)
+	fo.Publish(2)
+
+	select {
+	case slot := <-ch:
+		assert.Equal(t, 1, slot.Elem)
+	case <-time.After(time.Second):
+		t.Fatal("expected element not received")
+	}
+
+	mustNoErr(cancel())
+
+	// Remaining elements can still be received.
+	var got []int
+	for slot := range ch {
+		got = append(got, slot.Elem)
+	}
+	assert.Equal(t, []int{2}, got)
+}
+
+func TestSubscription_Chan_SameChannel(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	assert.Equal(t, sub.Chan(), sub.Chan())
+}
+
+func TestSubscription_All(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+
+	fo.Publish(1)
+	fo.Publish(2)
+	fo.Publish(3)
+	mustNoErr(sub.Cancel())
+
+	var got []int
+	sub.All()(func(slot *flow.Slot[int]) bool {
+		got = append(got, slot.Elem)
+		return true
+	})
+	assert.Equal(t, []int{1, 2, 3}, got, "iteration should drain remaining elements")
+}
+
+func TestSubscription_All_Break(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	fo.Publish(1)
+	fo.Publish(2)
+
+	sub.All()(func(slot *flow.Slot[int]) bool {
+		return false
+	})
+
+	slot, err := sub.Consume()
+	assert.NoError(t, err, "breaking the iteration should not cancel the subscription")
+	assert.Equal(t, 2, slot.Elem)
+}
+
+func TestSubscription_ConsumeBatch_Max(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	for i := 1; i <= 5; i++ {
+		fo.Publish(i)
+	}
+
+	batch, err := sub.ConsumeBatch(context.Background(), 3, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, batchElems(batch))
+}
+
+func TestSubscription_ConsumeBatch_Wait(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		fo.Publish(1)
+		fo.Publish(2)
+	})
+
+	start := time.Now()
+	batch, err := sub.ConsumeBatch(context.Background(), 10, 50*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, batchElems(batch))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(100*time.Millisecond),
+		"wait time should count from the first element arrival")
+}
+
+func TestSubscription_ConsumeBatch_PartialOnEOF(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+
+	fo.Publish(1)
+	fo.Publish(2)
+	mustNoErr(sub.Cancel())
+
+	batch, err := sub.ConsumeBatch(context.Background(), 10, time.Second)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []int{1, 2}, batchElems(batch))
+
+	batch, err = sub.ConsumeBatch(context.Background(), 10, time.Second)
+	assert.Equal(t, io.EOF, err)
+	assert.Empty(t, batch)
+}
+
+func TestSubscription_ConsumeBatch_Context(t *testing.T) {
+	fo := flow.NewFanout[int](10)
+	sub := fo.NewSubscription("")
+	defer sub.Cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	fo.Publish(1)
+
+	batch, err := sub.ConsumeBatch(ctx, 10, time.Second)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, []int{1}, batchElems(batch))
+}
+
+func batchElems[T any](batch []*flow.Slot[T]) []T {
+	elems := make([]T, len(batch))
+	for i := range batch {
+		elems[i] = batch[i].Elem
+	}
+	return elems
+}
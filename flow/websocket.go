@@ -0,0 +1,269 @@
+package flow
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This is a minimal server side implementation of the WebSocket
+// protocol (RFC 6455). Only what is needed for pushing elements
+// to clients is implemented. Client messages are discarded.
+
+const (
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsWriteTimeout = 10 * time.Second
+	// wsMaxControlLen is the maximum payload
+	// length of control frames, like ping.
+	wsMaxControlLen = 125
+
+	wsOpText   = 0x1
+	wsOpBinary = 0x2
+	wsOpClose  = 0x8
+	wsOpPing   = 0x9
+	wsOpPong   = 0xA
+)
+
+var errWSProtocol = errors.New("websocket: protocol error")
+
+type wsHandler[T any] struct {
+	*bridge[T]
+}
+
+// NewWebSocketHandler is same as NewSSEHandler, but it streams
+// the elements using the WebSocket protocol. Each element is
+// sent as a text message, with the output of the provided Encoder,
+// which must be valid UTF-8. Otherwise, see WithBridgeBinary.
+//
+// Heartbeats are sent as ping frames. As browsers cannot set
+// headers in WebSocket connections, there is no replay support.
+// Messages sent by clients are discarded.
+//
+// Requests from other origins than the handler host are rejected
+// with 403 Forbidden. See WithBridgeOrigins.
+func NewWebSocketHandler[T any](fo *Fanout[T], enc Encoder[T], opts ...BridgeOpt[T]) http.Handler {
+	return &wsHandler[T]{newBridge(fo, enc, opts...)}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *wsHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.allowedOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, err := wsUpgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sub := h.subscribe()
+	defer sub.Cancel() //nolint:errcheck
+
+	// Clients messages need to be read, in order to
+	// detect disconnections and reply to control frames.
+	go conn.readLoop()
+
+	heartbeats, stop := h.heartbeats()
+	defer stop()
+
+	opcode := byte(wsOpText)
+	if h.binary {
+		opcode = wsOpBinary
+	}
+
+	for {
+		select {
+		case <-conn.closed:
+			return
+		case <-heartbeats:
+			err = conn.writeFrame(wsOpPing, nil)
+		case slot, ok := <-sub.sub.ch:
+			slot, rErr := sub.received(slot, ok)
+			if rErr == errExpired {
+				continue
+			}
+			if rErr != nil {
+				_ = conn.writeFrame(wsOpClose, nil)
+				return
+			}
+			data, encErr := h.enc.Encode(slot.Elem)
+			if encErr != nil {
+				continue
+			}
+			err = conn.writeFrame(opcode, data)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wsConn represents an established WebSocket connection.
+type wsConn struct {
+	conn      net.Conn
+	rw        *bufio.ReadWriter
+	closed    chan struct{}
+	closeOnce sync.Once
+	l         sync.Mutex
+}
+
+// wsUpgrade performs the opening handshake. In case of
+// failure, the response is already sent to the client.
+func wsUpgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		key == "" {
+		http.Error(w, "websocket handshake expected", http.StatusBadRequest)
+		return nil, errWSProtocol
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, errWSProtocol
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID)) //nolint:gosec
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, rw: rw, closed: make(chan struct{})}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame writes a single, not fragmented, frame. As
+// defined by the protocol, server frames are not masked.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode // FIN bit set.
+	switch n := len(payload); {
+	case n <= wsMaxControlLen:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// readLoop reads the client frames until the connection is
+// closed or a close frame is received. Ping frames are
+// replied, the rest are discarded.
+func (c *wsConn) readLoop() {
+	defer c.closeOnce.Do(func() { close(c.closed) })
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpClose:
+			_ = c.writeFrame(wsOpClose, nil)
+			return
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readFrame reads the next client frame. Only control frames
+// payloads are returned, as data frames are discarded.
+func (c *wsConn) readFrame() (opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.rw, header); err != nil {
+		return 0, nil, err
+	}
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	if !masked {
+		// Clients must always mask their frames.
+		return 0, nil, errWSProtocol
+	}
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.rw, mask); err != nil {
+		return 0, nil, err
+	}
+	if opcode < wsOpClose {
+		_, err := io.CopyN(io.Discard, c.rw, int64(length))
+		return opcode, nil, err
+	}
+	if length > wsMaxControlLen {
+		return 0, nil, errWSProtocol
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// Close closes the underlying connection.
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.conn.Close()
+}
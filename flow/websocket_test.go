@@ -0,0 +1,224 @@
+//go:build unit
+
+package flow_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/kit/flow"
+)
+
+const (
+	wsOpText   = 0x1
+	wsOpBinary = 0x2
+	wsOpClose  = 0x8
+	wsOpPing   = 0x9
+	wsOpPong   = 0xA
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	conn, r := wsDial(t, srv.URL)
+	defer conn.Close()
+	waitSubscribers(t, fo, 1)
+
+	fo.Publish("a")
+	fo.Publish(strings.Repeat("b", 200))
+
+	opcode, payload := wsReadFrame(t, r)
+	assert.Equal(t, byte(wsOpText), opcode)
+	assert.Equal(t, "a", string(payload))
+	opcode, payload = wsReadFrame(t, r)
+	assert.Equal(t, byte(wsOpText), opcode)
+	assert.Equal(t, strings.Repeat("b", 200), string(payload))
+}
+
+func TestWebSocketHandler_Binary(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{}, flow.WithBridgeBinary[string]()))
+	defer srv.Close()
+
+	conn, r := wsDial(t, srv.URL)
+	defer conn.Close()
+	waitSubscribers(t, fo, 1)
+
+	mustPublish(fo, "\xff\xfe")
+
+	opcode, payload := wsReadFrame(t, r)
+	assert.Equal(t, byte(wsOpBinary), opcode)
+	assert.Equal(t, []byte{0xff, 0xfe}, payload)
+}
+
+func TestWebSocketHandler_PingPong(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	conn, r := wsDial(t, srv.URL)
+	defer conn.Close()
+
+	wsWriteFrame(t, conn, wsOpPing, []byte("hi"))
+	opcode, payload := wsReadFrame(t, r)
+	assert.Equal(t, byte(wsOpPong), opcode)
+	assert.Equal(t, "hi", string(payload))
+}
+
+func TestWebSocketHandler_Heartbeat(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{},
+		flow.WithBridgeHeartbeat[string](10*time.Millisecond),
+	))
+	defer srv.Close()
+
+	conn, r := wsDial(t, srv.URL)
+	defer conn.Close()
+
+	opcode, _ := wsReadFrame(t, r)
+	assert.Equal(t, byte(wsOpPing), opcode)
+}
+
+func TestWebSocketHandler_CloseCancelsSubscription(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	conn, r := wsDial(t, srv.URL)
+	defer conn.Close()
+	waitSubscribers(t, fo, 1)
+
+	wsWriteFrame(t, conn, wsOpClose, nil)
+	opcode, _ := wsReadFrame(t, r)
+	assert.Equal(t, byte(wsOpClose), opcode)
+	waitSubscribers(t, fo, 0)
+}
+
+func TestWebSocketHandler_DisconnectCancelsSubscription(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	conn, _ := wsDial(t, srv.URL)
+	waitSubscribers(t, fo, 1)
+
+	conn.Close()
+	waitSubscribers(t, fo, 0)
+}
+
+func TestWebSocketHandler_BadHandshake(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 0, fo.ActiveSubscribers())
+}
+
+func TestWebSocketHandler_Origin(t *testing.T) {
+	fo := flow.NewFanout[string](10)
+	srv := httptest.NewServer(flow.NewWebSocketHandler[string](fo, stringEncoder{},
+		flow.WithBridgeOrigins[string]("https://app.example.com"),
+	))
+	defer srv.Close()
+
+	cases := map[string]int{
+		"":                        http.StatusSwitchingProtocols,
+		"http://localhost":        http.StatusSwitchingProtocols,
+		"https://app.example.com": http.StatusSwitchingProtocols,
+		"https://evil.example":    http.StatusForbidden,
+	}
+	for origin, want := range cases {
+		conn, _, resp := wsHandshake(t, srv.URL, origin)
+		assert.Equal(t, want, resp.StatusCode, "origin %q", origin)
+		conn.Close()
+	}
+	waitSubscribers(t, fo, 0)
+}
+
+// wsDial performs the opening handshake, using the
+// example key of the RFC 6455.
+func wsDial(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, r, resp := wsHandshake(t, url, "")
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	return conn, r
+}
+
+// wsHandshake sends the opening handshake, with the provided
+// Origin header, if any, and returns the server response.
+func wsHandshake(t *testing.T, url, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	if origin != "" {
+		origin = "Origin: " + origin + "\r\n"
+	}
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		origin+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	require.NoError(t, err)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	return conn, r, resp
+}
+
+// wsReadFrame reads a not masked and not fragmented frame.
+func wsReadFrame(t *testing.T, r *bufio.Reader) (opcode byte, payload []byte) {
+	t.Helper()
+	header := make([]byte, 2)
+	_, err := io.ReadFull(r, header)
+	require.NoError(t, err)
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		_, err = io.ReadFull(r, ext)
+		require.NoError(t, err)
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		_, err = io.ReadFull(r, ext)
+		require.NoError(t, err)
+		length = binary.BigEndian.Uint64(ext)
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	require.NoError(t, err)
+	return header[0] & 0x0F, payload
+}
+
+// wsWriteFrame writes a masked control frame, as clients must do.
+func wsWriteFrame(t *testing.T, w io.Writer, opcode byte, payload []byte) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i := range payload {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	require.NoError(t, err)
+}
@@ -0,0 +1,162 @@
+package moment
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Clock provides the current time and timers. The target
+// is the same as NowFunc, but for code that also needs to
+// wait, so time based tests do not need to sleep.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer represents a single event. The current
+// time will be sent on C once the timer expires.
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing. It returns
+	// false if the timer already expired or was stopped.
+	Stop() bool
+}
+
+// NewClock returns a Clock backed by the
+// time package, ready for production code.
+func NewClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// FakeClock is a Clock whose time only moves when Advance
+// is called, firing the expired timers. Ready to be used in
+// tests. Its safe for concurrent use.
+type FakeClock struct {
+	now     time.Time
+	timers  []*fakeTimer
+	created int
+	cond    *sync.Cond
+	l       sync.Mutex
+}
+
+// NewFakeClock returns a FakeClock, whose
+// current time is the provided date.
+func NewFakeClock(t *testing.T, date string) *FakeClock {
+	c := &FakeClock{now: NewFakedNow(t, date)()}
+	c.cond = sync.NewCond(&c.l)
+	return c
+}
+
+// Now returns the current time of the clock. The method
+// value can be used as a NowFunc.
+func (c *FakeClock) Now() time.Time {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.now
+}
+
+// NewTimer creates a Timer that will fire once the
+// clock is advanced, at least, the provided duration.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.l.Lock()
+	defer c.l.Unlock()
+	t := &fakeTimer{
+		c:        c,
+		ch:       make(chan time.Time, 1),
+		deadline: c.now.Add(d),
+	}
+	c.created++
+	if d <= 0 {
+		t.ch <- c.now
+	} else {
+		c.timers = append(c.timers, t)
+	}
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by the provided
+// duration, firing all the expired timers in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	var pending []*fakeTimer
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- t.deadline
+	}
+	c.timers = pending
+	c.cond.Broadcast()
+}
+
+// BlockUntilCreated blocks until the provided number of timers
+// were created, in total, by the clock. Useful for knowing
+// when the code under test is waiting, before advancing it.
+func (c *FakeClock) BlockUntilCreated(n int) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	for c.created < n {
+		c.cond.Wait()
+	}
+}
+
+// Timers returns the number of timers waiting to be fired.
+func (c *FakeClock) Timers() int {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	c        *FakeClock
+	ch       chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.l.Lock()
+	defer t.c.l.Unlock()
+	for i, pending := range t.c.timers {
+		if pending == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			t.c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+//go:build unit
+
+package moment_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/kit/moment"
+)
+
+func TestFakeClock(t *testing.T) {
+	c := moment.NewFakeClock(t, fixture)
+	start := c.Now()
+
+	t1 := c.NewTimer(2 * time.Second)
+	t2 := c.NewTimer(time.Second)
+	t3 := c.NewTimer(3 * time.Second)
+	assert.Equal(t, 3, c.Timers())
+
+	c.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), c.Now())
+	assert.Equal(t, start.Add(time.Second), <-t2.C())
+	assert.Len(t, t1.C(), 0)
+
+	assert.True(t, t3.Stop())
+	assert.False(t, t3.Stop())
+
+	c.Advance(5 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-t1.C(), "timers fire at their deadline")
+	assert.Len(t, t3.C(), 0, "stopped timers never fire")
+	assert.False(t, t1.Stop())
+	assert.Equal(t, 0, c.Timers())
+}
+
+func TestFakeClock_ExpiredTimer(t *testing.T) {
+	c := moment.NewFakeClock(t, fixture)
+	timer := c.NewTimer(0)
+	assert.Equal(t, c.Now(), <-timer.C())
+	assert.Equal(t, 0, c.Timers())
+}
+
+func TestFakeClock_BlockUntilCreated(t *testing.T) {
+	c := moment.NewFakeClock(t, fixture)
+	fired := make(chan time.Time)
+	go func() {
+		fired <- <-c.NewTimer(time.Second).C()
+	}()
+	c.BlockUntilCreated(1)
+	c.Advance(time.Second)
+	assert.Equal(t, c.Now(), <-fired)
+}
+
+func TestClock(t *testing.T) {
+	c := moment.NewClock()
+	start := c.Now()
+	timer := c.NewTimer(time.Millisecond)
+	assert.False(t, (<-timer.C()).Before(start.Add(time.Millisecond)))
+}